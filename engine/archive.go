@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Kinds of archives that are opened by the secret finder.
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+// Separates the path of an archive from the path of an entry inside it.
+const archiveSeparator = "!/"
+
+// Helper function to return the kind of archive the given file name
+// refers to, or an empty string if it is not a supported archive.
+func archiveKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".jar"),
+		strings.HasSuffix(name, ".war"), strings.HasSuffix(name, ".ear"):
+		return archiveZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar
+	}
+	return ""
+}
+
+// State kept while the entries of an archive found in the repository,
+// and of the archives nested in it, are scanned.
+type archiveScan struct {
+	finder *SecretFinder
+	// number of bytes that can still be extracted
+	budget int64
+}
+
+// Helper function to scan the entries of an archive file of the
+// repository as if they were files located under the archive path.
+func (a *SecretFinder) scanArchiveFile(path, kind string, file *os.File, size int64) error {
+	as := &archiveScan{
+		finder: a,
+		budget: a.config.Limits.MaxArchiveSize,
+	}
+
+	if size > as.budget {
+		a.skip(path, skipTooLarge)
+		return nil
+	}
+
+	return as.scan(path, kind, file, size, 1)
+}
+
+// Helper function to scan the entries of an archive read from r, which
+// is nested depth levels deep.
+func (as *archiveScan) scan(path, kind string, r io.Reader, size int64, depth int) error {
+	switch kind {
+	case archiveZip:
+		return as.scanZip(path, r, size, depth)
+	case archiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return as.scanTar(path, gz, depth)
+	default:
+		return as.scanTar(path, r, depth)
+	}
+}
+
+func (as *archiveScan) scanZip(path string, r io.Reader, size int64, depth int) error {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		// nested archives are extracted to memory, within the limits
+		// checked by scanEntry
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			as.entryFailed(path+archiveSeparator+f.Name, err)
+			continue
+		}
+		as.scanEntry(path+archiveSeparator+f.Name, rc, int64(f.UncompressedSize64), depth)
+		rc.Close()
+	}
+
+	return nil
+}
+
+func (as *archiveScan) scanTar(path string, r io.Reader, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		as.scanEntry(path+archiveSeparator+strings.TrimPrefix(hdr.Name, "./"), tr, hdr.Size, depth)
+	}
+}
+
+// Helper function to scan an entry of an archive. Entries are excluded
+// by the same patterns as files, matched against the path of the entry
+// under the archive path. The size of the entry is checked against the
+// limits before anything is extracted; the archive readers fail if an
+// entry holds more data than declared.
+func (as *archiveScan) scanEntry(path string, r io.Reader, size int64, depth int) {
+	a := as.finder
+	if a.isExcluded(strings.TrimPrefix(path, a.basepath), false) {
+		return
+	}
+
+	if size > a.config.Limits.MaxFileSize || size > as.budget {
+		a.skip(path, skipTooLarge)
+		return
+	}
+	as.budget -= size
+
+	if kind := archiveKind(path); kind != "" {
+		if depth >= a.config.Limits.MaxArchiveDepth {
+			a.skip(path, skipTooDeep)
+			return
+		}
+		if err := as.scan(path, kind, r, size, depth+1); err != nil {
+			as.entryFailed(path, err)
+		}
+		return
+	}
+
+	// binary entries, such as compiled classes, are too common in
+	// archives to be worth listing
+	if err := a.scanReader(path, r); err != nil && err != errBinaryContent {
+		as.entryFailed(path, err)
+	}
+}
+
+// Helper function to report an entry that could not be read.
+func (as *archiveScan) entryFailed(path string, err error) {
+	log.Printf("Error when scanning %v: %v", path, err.Error())
+	as.finder.skip(path, skipUnreadable)
+}
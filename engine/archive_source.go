@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Largest size the files of an uploaded archive can take once
+// extracted, unless the repository sets its own limit.
+const DefaultMaxExtractedSize int64 = 1 << 30
+
+var errExtractedSize = errors.New("extracted size exceeded")
+
+// ArchiveSource extracts an archive uploaded to the server, which can
+// be a zip file, a tarball or a gzipped tarball.
+type ArchiveSource struct {
+	Path string
+	// largest size of the extracted files, DefaultMaxExtractedSize if 0
+	MaxSize int64
+}
+
+func (as *ArchiveSource) Fetch(scratchDir string) (*Snapshot, error) {
+	kind, err := ArchiveFormat(as.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open archive: %v", err.Error())
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("unsupported archive format")
+	}
+
+	max := as.MaxSize
+	if max <= 0 {
+		max = DefaultMaxExtractedSize
+	}
+
+	x := &extraction{dir: scratchDir, budget: max}
+	if kind == archiveZip {
+		err = x.extractZip(as.Path)
+	} else {
+		err = x.extractTar(as.Path, kind == archiveTarGz)
+	}
+
+	if err == errExtractedSize {
+		return nil, fmt.Errorf("archive exceeds the maximum extracted size of %v bytes", max)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archive: %v", err.Error())
+	}
+
+	return &Snapshot{Dir: scratchDir}, nil
+}
+
+// Uploaded archives are owned by the caller.
+func (as *ArchiveSource) Release() {}
+
+// ArchiveFormat tells the kind of archive stored in the file from its
+// first bytes, or returns an empty string if the file is not a
+// supported archive.
+func ArchiveFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return archiveZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return archiveTarGz, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar, nil
+	}
+	return "", nil
+}
+
+// State kept while an archive is extracted.
+type extraction struct {
+	dir string
+	// number of bytes that can still be extracted
+	budget int64
+}
+
+func (x *extraction) extractZip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Mode().IsDir() {
+			if _, err := x.target(f.Name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// links and special files are left out
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = x.writeFile(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (x *extraction) extractTar(path string, gzipped bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// links and special files are left out
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		if err := x.writeFile(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Helper function to return where an entry of the archive is
+// extracted, refusing entries that would land outside of the
+// extraction directory.
+func (x *extraction) target(name string) (string, error) {
+	clean := path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if clean == "/" || strings.HasPrefix(name, "/") || strings.Contains("/"+name+"/", "/../") {
+		return "", fmt.Errorf("invalid entry name '%v'", name)
+	}
+
+	return filepath.Join(x.dir, filepath.FromSlash(clean)), nil
+}
+
+// Helper function to extract a file of the archive within the
+// remaining budget.
+func (x *extraction) writeFile(name string, r io.Reader) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	n, err := io.Copy(out, io.LimitReader(r, x.budget+1))
+	if err != nil {
+		return err
+	}
+	if n > x.budget {
+		return errExtractedSize
+	}
+	x.budget -= n
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package engine_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+)
+
+// Helper function to build a zip archive holding the given files.
+func makeZip(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedNames(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Could not add %v to zip: %v\n", name, err.Error())
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Could not write zip: %v\n", err.Error())
+	}
+	return buf.String()
+}
+
+// Helper function to build a gzip compressed tar archive holding the
+// given files.
+func makeTarGz(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedNames(files) {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Could not add %v to tar: %v\n", name, err.Error())
+		}
+		tw.Write([]byte(files[name]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Could not write tar: %v\n", err.Error())
+	}
+	gz.Close()
+	return buf.String()
+}
+
+func TestFindSecretsExcludesArchiveEntries(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"deps.zip": makeZip(t, map[string]string{
+			"config/app.env":        "private_key=abc\n",
+			"vendor/lib/lib.env":    "private_key=abc\n",
+			"fixtures/fixture.env":  "private_key=abc\n",
+			"vendor/lib/nested.zip": makeZip(t, map[string]string{"app.env": "private_key=abc\n"}),
+		}),
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	sf.SetExcludes([]string{"fixtures/"})
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 1 || findings[0].Location.Path != "/deps.zip!/config/app.env" {
+		t.Errorf("Expected only the entry outside of the excluded paths to be scanned. Got %v\n", len(findings))
+	}
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFindSecretsInsideArchives(t *testing.T) {
+	inner := makeZip(t, map[string]string{
+		"META-INF/app.properties": "name=app\nprivate_key=abc\n",
+		"com/example/App.class":   "\xca\xfe\xba\xbe\x00\x00",
+	})
+
+	dir := makeSourceTree(t, map[string]string{
+		"dist/bundle.zip": makeZip(t, map[string]string{
+			"config/file.properties": "private_key=abc\n",
+			"lib/inner.jar":          inner,
+		}),
+		"release.tar.gz": makeTarGz(t, map[string]string{
+			"./etc/app.env": "# settings\npublic_key=xyz\n",
+		}),
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	found := make(map[string]int32)
+	for _, fi := range findings {
+		found[fi.Location.Path] = fi.Location.Positions.Begin.Line
+	}
+
+	expected := map[string]int32{
+		"/dist/bundle.zip!/config/file.properties":                 1,
+		"/dist/bundle.zip!/lib/inner.jar!/META-INF/app.properties": 2,
+		"/release.tar.gz!/etc/app.env":                             2,
+	}
+
+	if len(found) != len(expected) {
+		t.Errorf("Expected %v findings. Got %v\n", len(expected), found)
+	}
+	for path, line := range expected {
+		if found[path] != line {
+			t.Errorf("Expected finding at %v:%v. Got %v\n", path, line, found)
+		}
+	}
+
+	if len(sf.Skipped()) != 0 {
+		t.Errorf("Expected no skipped files. Got %v\n", sf.Skipped())
+	}
+}
+
+func TestFindSecretsArchiveLimits(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte("limits:\n  maxArchiveDepth: 1\n  maxArchiveSize: 1000\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	dir := makeSourceTree(t, map[string]string{
+		"a.zip": makeZip(t, map[string]string{
+			"a.env":     "private_key=abc\n",
+			"big.txt":   strings.Repeat("x\n", 1000),
+			"inner.zip": makeZip(t, map[string]string{"b.env": "private_key=abc\n"}),
+		}),
+		"broken.zip": "not a zip file",
+	})
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 1 || findings[0].Location.Path != "/a.zip!/a.env" {
+		t.Errorf("Expected a single finding in /a.zip!/a.env. Got %v\n", findings)
+	}
+
+	skipped := make(map[string]string)
+	for _, s := range sf.Skipped() {
+		skipped[s.Path] = s.Reason
+	}
+
+	expected := map[string]string{
+		"/a.zip!/big.txt":   "too large",
+		"/a.zip!/inner.zip": "too deep",
+		"/broken.zip":       "unreadable",
+	}
+	if len(skipped) != len(expected) {
+		t.Errorf("Expected %v skipped files. Got %v\n", len(expected), skipped)
+	}
+	for path, reason := range expected {
+		if skipped[path] != reason {
+			t.Errorf("Expected %v to be skipped as %v. Got %v\n", path, reason, skipped)
+		}
+	}
+}
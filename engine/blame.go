@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// BlameFindings annotates the findings of a scan of the working tree of
+// the repository at the given path with the commit that last changed
+// the lines of their secret, as reported by git blame. Findings that
+// already carry a commit, findings inside archives and findings in
+// files that are not part of the HEAD commit are left as they are.
+// Returns an error if the repository cannot be read.
+func BlameFindings(repoPath string, findings []*models.FindingsInfo) error {
+	root, err := openBlameRepo(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// files are blamed once, and commits read once, for the whole scan
+	repos := make(map[string]*blameRepo)
+	blames := make(map[string]*git.BlameResult)
+	commits := make(map[plumbing.Hash]*models.CommitInfo)
+	for _, fi := range findings {
+		if fi.Commit != nil || fi.Location == nil || fi.Location.Positions == nil || fi.Location.Positions.Begin == nil {
+			continue
+		}
+
+		path := fi.Location.Path
+		if strings.Contains(path, archiveSeparator) {
+			continue
+		}
+
+		// files of submodules are blamed in the repository of the submodule
+		br, prefix := root, ""
+		if fi.Submodule != nil {
+			prefix = fi.Submodule.Path
+			sub, ok := repos[prefix]
+			if !ok {
+				if sub, err = openBlameRepo(filepath.Join(repoPath, filepath.FromSlash(prefix[1:]))); err != nil {
+					log.Printf("Cannot blame submodule %v: %v", prefix, err.Error())
+				}
+				repos[prefix] = sub
+			}
+			if sub == nil {
+				continue
+			}
+			br = sub
+		}
+
+		result, ok := blames[path]
+		if !ok {
+			if result, err = git.Blame(br.head, strings.TrimPrefix(path, prefix+"/")); err != nil {
+				log.Printf("Cannot blame %v: %v", path, err.Error())
+			}
+			blames[path] = result
+		}
+		if result == nil {
+			continue
+		}
+
+		line := blamedLine(result, fi.Location.Positions)
+		if line == nil {
+			continue
+		}
+
+		info, ok := commits[line.Hash]
+		if !ok {
+			if c, err := br.repo.CommitObject(line.Hash); err == nil {
+				info = commitInfo(c)
+			} else {
+				log.Printf("Cannot read commit %v: %v", line.Hash.String(), err.Error())
+			}
+			commits[line.Hash] = info
+		}
+		if info != nil {
+			c := *info
+			fi.Commit = &c
+		}
+	}
+
+	return nil
+}
+
+// A repository along with the commit checked out in it.
+type blameRepo struct {
+	repo *git.Repository
+	head *object.Commit
+}
+
+// Helper function to open the repository at the given path for blame.
+func openBlameRepo(repoPath string) (*blameRepo, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repository: %v", err.Error())
+	}
+
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blameRepo{repo: repo, head: head}, nil
+}
+
+// Helper function to return the most recently changed line of the
+// given range, or nil if the range is outside of the file.
+func blamedLine(br *git.BlameResult, fl *models.FileLocation) *git.Line {
+	first, last := int(fl.Begin.Line), int(fl.Begin.Line)
+	if fl.End != nil && int(fl.End.Line) > last {
+		last = int(fl.End.Line)
+	}
+
+	var latest *git.Line
+	for n := first; n <= last && n <= len(br.Lines); n++ {
+		if n < 1 {
+			continue
+		}
+		if l := br.Lines[n-1]; latest == nil || l.Date.After(latest.Date) {
+			latest = l
+		}
+	}
+
+	return latest
+}
@@ -0,0 +1,93 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+func TestBlameFindingsAttributesCommits(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"config/app.env": "name=app\n", "README.md": "hello\n"},
+		{"config/app.env": "name=app\nprivate_key=k8Jd93hsQ\n"},
+		{"README.md": "hello world\n"},
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding. Got %v\n", len(findings))
+	}
+
+	if err := engine.BlameFindings(dir, findings); err != nil {
+		t.Fatalf("Failed to blame findings: %v\n", err.Error())
+	}
+
+	c := findings[0].Commit
+	if c == nil {
+		t.Fatalf("Expected the finding to be attributed to a commit\n")
+	}
+	if c.Sha != hashes[1] {
+		t.Errorf("Expected commit %v. Got %v\n", hashes[1], c.Sha)
+	}
+	if c.Author != "Dev Eloper" || c.Email != "dev@example.com" || c.Timestamp != "2022-06-01T13:00:00Z" {
+		t.Errorf("Expected the author and date of the commit. Got %v\n", c)
+	}
+}
+
+func TestBlameFindingsUsesLatestLineOfRange(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.go": "a\nb\nc\n"},
+		{"a.go": "a\nB\nc\n"},
+	})
+
+	findings := []*models.FindingsInfo{
+		{
+			RuleId: "G014",
+			Location: &models.FindingsLocation{
+				Path: "/a.go",
+				Positions: &models.FileLocation{
+					Begin: &models.LineLocation{Line: 1},
+					End:   &models.LineLocation{Line: 3},
+				},
+			},
+		},
+		{
+			RuleId: "G014",
+			Location: &models.FindingsLocation{
+				Path:      "/untracked.go",
+				Positions: &models.FileLocation{Begin: &models.LineLocation{Line: 1}},
+			},
+		},
+		{
+			RuleId: "G014",
+			Location: &models.FindingsLocation{
+				Path:      "/a.go",
+				Positions: &models.FileLocation{Begin: &models.LineLocation{Line: 1}},
+			},
+			Commit: &models.CommitInfo{Sha: "kept"},
+		},
+	}
+
+	if err := engine.BlameFindings(dir, findings); err != nil {
+		t.Fatalf("Failed to blame findings: %v\n", err.Error())
+	}
+
+	if findings[0].Commit == nil || findings[0].Commit.Sha != hashes[1] {
+		t.Errorf("Expected the latest commit of the range. Got %v\n", findings[0].Commit)
+	}
+	if findings[1].Commit != nil {
+		t.Errorf("Expected untracked files not to be attributed. Got %v\n", findings[1].Commit)
+	}
+	if findings[2].Commit.Sha != "kept" {
+		t.Errorf("Expected existing commits to be kept. Got %v\n", findings[2].Commit)
+	}
+}
+
+func TestBlameFindingsInvalidRepository(t *testing.T) {
+	if err := engine.BlameFindings(t.TempDir(), nil); err == nil {
+		t.Errorf("Expected an error for a directory that is not a repository\n")
+	}
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Confidence levels that can be assigned to a rule and to a finding,
+// from the most to the least likely to be a real secret.
+var confidenceLevels = []string{"HIGH", "MEDIUM", "LOW"}
+
+// Score of a finding before adjustments, by confidence of its rule.
+var confidenceBaseScore = map[string]int{"HIGH": 80, "MEDIUM": 60, "LOW": 30}
+
+// Adjustments applied to the base score of a finding.
+const (
+	highEntropyBonus    = 10  // random looking secrets
+	lowEntropyPenalty   = -10 // secrets that look like words
+	lowPathPenalty      = -30 // tests, documentation and examples
+	placeholderPenalty  = -15 // lines that mention a placeholder
+	highEntropyBits     = 4.0
+	lowEntropyBits      = 3.0
+	minHighConfidence   = 70
+	minMediumConfidence = 40
+)
+
+// Helper function to prepare the matcher for the paths whose findings
+// are less likely to be real secrets.
+func (cs *ConfidenceSettings) compile() {
+	patterns := make([]gitignore.Pattern, 0, len(cs.LowPaths))
+	for _, p := range cs.LowPaths {
+		patterns = appendPattern(patterns, p)
+	}
+
+	cs.lowPaths = gitignore.NewMatcher(patterns)
+}
+
+// Helper function to check whether the file at the given path, relative
+// to the root of the scanned tree, holds tests, documentation or
+// examples.
+func (cs *ConfidenceSettings) isLowPath(relpath string) bool {
+	if cs.lowPaths == nil {
+		return false
+	}
+
+	parts := strings.Split(strings.Trim(relpath, "/"), "/")
+	return cs.lowPaths.Match(parts, false)
+}
+
+// Helper function to score the likelihood that the match is a real
+// secret, from 0 to 100, and return the matching confidence level. The
+// score starts from the confidence of the rule, and is adjusted by the
+// entropy of the secret, the kind of file it was found in and the
+// placeholders mentioned around it.
+func (c *Config) confidence(m *match, relpath string) (string, int32) {
+	base, ok := confidenceBaseScore[m.rule.Confidence]
+	if !ok {
+		base = confidenceBaseScore["MEDIUM"]
+	}
+	score := base
+
+	// private key blocks are recognized by their structure
+	if m.rule.Analyzer != analyzerPrivateKey && m.rule.Analyzer != analyzerEncryptedPrivateKey {
+		if e := shannonEntropy(normalizeSecret(m.secret)); e >= highEntropyBits {
+			score += highEntropyBonus
+		} else if e < lowEntropyBits {
+			score += lowEntropyPenalty
+		}
+	}
+
+	if c.Confidence.isLowPath(relpath) {
+		score += lowPathPenalty
+	}
+
+	around := strings.ToLower(strings.Replace(m.context, m.secret, "", 1))
+	for _, p := range c.Filters.placeholders {
+		if strings.Contains(around, p) {
+			score += placeholderPenalty
+			break
+		}
+	}
+
+	if score < 0 {
+		score = 0
+	} else if score > 100 {
+		score = 100
+	}
+
+	return confidenceLevel(score), int32(score)
+}
+
+// Helper function to return the confidence level of a score.
+func confidenceLevel(score int) string {
+	switch {
+	case score >= minHighConfidence:
+		return "HIGH"
+	case score >= minMediumConfidence:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+// ParseConfidence returns the confidence level with the given name,
+// regardless of case, or an error if there is no such level.
+func ParseConfidence(level string) (string, error) {
+	level = strings.ToUpper(level)
+	for _, l := range confidenceLevels {
+		if level == l {
+			return level, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid confidence '%v'", level)
+}
+
+// ConfidenceAtLeast reports whether the confidence level is the same
+// as, or higher than, the minimum level. Findings without a confidence
+// level, such as those of older scans, are below every level.
+func ConfidenceAtLeast(level, min string) bool {
+	for _, l := range confidenceLevels {
+		if l == level {
+			return true
+		}
+		if l == min {
+			return false
+		}
+	}
+
+	return false
+}
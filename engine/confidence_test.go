@@ -0,0 +1,79 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+const awsKeyId = "AKIA" + "IOSFODNN7QWERTYU"
+
+func findingAt(findings []*models.FindingsInfo, path string) *models.FindingsInfo {
+	for _, fi := range findings {
+		if fi.Location.Path == path {
+			return fi
+		}
+	}
+	return nil
+}
+
+func TestFindSecretsScoresConfidence(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"src/aws.go":       "key := \"" + awsKeyId + "\"\n",
+		"src/aws_test.go":  "key := \"" + awsKeyId + "\"\n",
+		"docs/setup.md":    "key: " + awsKeyId + "\n",
+		"src/commented.go": "key := \"" + awsKeyId + "\" // your key goes here\n",
+		"src/db.go":        "password = \"hunter22\"\n",
+		"src/random.go":    "token := \"" + "q8Zr2Lw9Xk4Pm7Tn1Vb6Yc3Hd5Jf0Gs" + "\"\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	expected := map[string]string{
+		"/src/aws.go":       "HIGH",
+		"/src/aws_test.go":  "MEDIUM",
+		"/docs/setup.md":    "MEDIUM",
+		"/src/commented.go": "MEDIUM",
+		"/src/db.go":        "MEDIUM",
+		"/src/random.go":    "MEDIUM",
+	}
+	for path, confidence := range expected {
+		fi := findingAt(findings, path)
+		if fi == nil {
+			t.Errorf("Expected a finding in %v\n", path)
+		} else if fi.Metadata.Confidence != confidence {
+			t.Errorf("Expected %v confidence for %v. Got %v (%v)\n", confidence, path, fi.Metadata.Confidence, fi.Metadata.Score)
+		}
+	}
+
+	plain, test := findingAt(findings, "/src/aws.go"), findingAt(findings, "/src/aws_test.go")
+	if plain != nil && test != nil && plain.Metadata.Score <= test.Metadata.Score {
+		t.Errorf("Expected findings in tests to score lower. Got %v and %v\n", plain.Metadata.Score, test.Metadata.Score)
+	}
+}
+
+func TestFindSecretsConfidenceIsConfigurable(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"src/aws.go":       "key := \"" + awsKeyId + "\"\n",
+		"build/gen/aws.go": "key := \"" + awsKeyId + "\"\n",
+	})
+
+	cfg, err := engine.ParseConfig([]byte("confidence:\n  lowPaths: [gen/]\nrules:\n  - id: G003\n    confidence: low\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	if fi := findingAt(findings, "/src/aws.go"); fi == nil || fi.Metadata.Confidence != "LOW" {
+		t.Errorf("Expected the confidence of the rule to be overridden. Got %v\n", fi)
+	}
+	if fi := findingAt(findings, "/build/gen/aws.go"); fi == nil || fi.Metadata.Score != 0 {
+		t.Errorf("Expected added low paths to lower the score. Got %v\n", fi)
+	}
+}
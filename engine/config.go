@@ -0,0 +1,366 @@
+package engine
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the detection rules used by the secret finder, along
+// with the tuning parameters of its analyzers. A Config is read-only
+// once it has been loaded, so the same instance can be shared between
+// concurrent scans.
+type Config struct {
+	Rules        []*RuleDefinition    `yaml:"rules"`
+	Entropy      EntropySettings      `yaml:"entropy"`
+	Decoding     DecodingSettings     `yaml:"decoding"`
+	Filters      FilterSettings       `yaml:"filters"`
+	Confidence   ConfidenceSettings   `yaml:"confidence"`
+	Verification VerificationSettings `yaml:"verification"`
+	Excludes     []string             `yaml:"excludes"`
+	Limits       LimitSettings        `yaml:"limits"`
+
+	ruleIndex map[string]*RuleDefinition
+}
+
+// Thresholds used by the entropy analyzer. Strings shorter than
+// MinLength are ignored, longer ones are reported when their Shannon
+// entropy in bits per character reaches the threshold for their
+// character set.
+type EntropySettings struct {
+	MinLength       int     `yaml:"minLength"`
+	Base64Threshold float64 `yaml:"base64Threshold"`
+	HexThreshold    float64 `yaml:"hexThreshold"`
+}
+
+// Settings of the decoding pass, which decodes base64 and hex runs of
+// at least MinLength characters and runs the rules again on the
+// decoded text. The pass is enabled unless explicitly disabled.
+type DecodingSettings struct {
+	Enabled   *bool `yaml:"enabled"`
+	MinLength int   `yaml:"minLength"`
+}
+
+// IsEnabled reports whether encoded secrets are looked for.
+func (ds *DecodingSettings) IsEnabled() bool {
+	return ds.Enabled == nil || *ds.Enabled
+}
+
+// Settings of the false positive filter, which discards the matches
+// whose secret contains one of the Placeholders, unless their rule has
+// a HIGH confidence, starts with one of the References to another
+// value, repeats one or two characters or has an entropy lower than
+// MinEntropy bits per character. The filter is enabled unless
+// explicitly disabled. With Debug set, every discarded
+// match is logged along with the reason it was discarded.
+type FilterSettings struct {
+	Enabled      *bool    `yaml:"enabled"`
+	Placeholders []string `yaml:"placeholders"`
+	References   []string `yaml:"references"`
+	MinEntropy   float64  `yaml:"minEntropy"`
+	Debug        bool     `yaml:"debug"`
+
+	placeholders []string
+	references   []*regexp.Regexp
+}
+
+// IsEnabled reports whether false positives are filtered out.
+func (fs *FilterSettings) IsEnabled() bool {
+	return fs.Enabled == nil || *fs.Enabled
+}
+
+// Settings of the confidence scoring. Findings in files matching one
+// of the LowPaths, using the gitignore syntax, such as tests,
+// documentation and examples, are less likely to be real secrets.
+type ConfidenceSettings struct {
+	LowPaths []string `yaml:"lowPaths"`
+
+	lowPaths gitignore.Matcher
+}
+
+// Settings of the verification of the secrets found by the rules with
+// a verifier, which asks the service that issued a secret whether it
+// is live. Requests time out after Timeout seconds, and at most
+// RateLimit requests are sent to each service per second. Verifiers
+// send their requests to the BaseUrl of their settings. Verification is
+// disabled unless explicitly enabled.
+type VerificationSettings struct {
+	Enabled   *bool                        `yaml:"enabled"`
+	Timeout   int                          `yaml:"timeout"`
+	RateLimit float64                      `yaml:"rateLimit"`
+	Verifiers map[string]*VerifierSettings `yaml:"verifiers"`
+
+	verifiers map[string]*limitedVerifier
+}
+
+// Settings of a verifier.
+type VerifierSettings struct {
+	BaseUrl string `yaml:"baseUrl"`
+}
+
+// IsEnabled reports whether secrets are verified.
+func (vs *VerificationSettings) IsEnabled() bool {
+	return vs.Enabled != nil && *vs.Enabled
+}
+
+// Limits on the contents scanned by the secret finder. Files larger
+// than MaxFileSize bytes are skipped, lines longer than MaxLineLength
+// bytes are scanned in chunks. Archives are opened up to
+// MaxArchiveDepth levels of nesting, and at most MaxArchiveSize bytes
+// are extracted from each archive found in the repository.
+type LimitSettings struct {
+	MaxFileSize     int64 `yaml:"maxFileSize"`
+	MaxLineLength   int   `yaml:"maxLineLength"`
+	MaxArchiveDepth int   `yaml:"maxArchiveDepth"`
+	MaxArchiveSize  int64 `yaml:"maxArchiveSize"`
+}
+
+//go:embed rules/default.yaml
+var defaultRulePack []byte
+
+var defaultConfigOnce sync.Once
+var defaultConfig *Config
+
+// DefaultConfig returns the configuration built from the default
+// rule pack that is compiled into the binary.
+func DefaultConfig() *Config {
+	defaultConfigOnce.Do(func() {
+		cfg, err := parseRulePack(defaultRulePack)
+		if err == nil {
+			err = cfg.compile()
+		}
+		if err != nil {
+			log.Fatalf("Invalid default rule pack: %v", err.Error())
+		}
+		defaultConfig = cfg
+	})
+
+	return defaultConfig
+}
+
+// LoadConfig reads the rule file at the given path and layers it on
+// top of the default rule pack. Returns nil and an error if the file
+// cannot be read or the resulting rule set is invalid.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rule file %v: %v", path, err.Error())
+	}
+
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule file %v: %v", path, err.Error())
+	}
+
+	return cfg, nil
+}
+
+// ParseConfig layers the given YAML rule file contents on top of the
+// default rule pack and validates the result. Rules with an id that
+// already exists are tuned field by field, other rules are appended.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg, err := parseRulePack(defaultRulePack)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, err := parseRulePack(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.merge(overlay)
+
+	if err := cfg.compile(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Helper function to decode a YAML rule pack without validating it.
+func parseRulePack(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse rule pack: %v", err.Error())
+	}
+
+	seen := make(map[string]bool)
+	for i, rd := range cfg.Rules {
+		if rd == nil {
+			return nil, fmt.Errorf("rule #%v is empty", i+1)
+		}
+		if rd.Id == "" {
+			return nil, fmt.Errorf("rule #%v has no id", i+1)
+		}
+		if seen[rd.Id] {
+			return nil, fmt.Errorf("rule %v is defined more than once", rd.Id)
+		}
+		seen[rd.Id] = true
+	}
+
+	return &cfg, nil
+}
+
+// Helper function to apply the rules of another configuration on
+// top of this one.
+func (c *Config) merge(o *Config) {
+	index := make(map[string]*RuleDefinition)
+	for _, rd := range c.Rules {
+		index[rd.Id] = rd
+	}
+
+	for _, rd := range o.Rules {
+		if existing, ok := index[rd.Id]; ok {
+			existing.merge(rd)
+		} else {
+			c.Rules = append(c.Rules, rd)
+			index[rd.Id] = rd
+		}
+	}
+
+	// later patterns take precedence, so defaults can be negated
+	c.Excludes = append(c.Excludes, o.Excludes...)
+
+	if o.Entropy.MinLength != 0 {
+		c.Entropy.MinLength = o.Entropy.MinLength
+	}
+	if o.Entropy.Base64Threshold != 0 {
+		c.Entropy.Base64Threshold = o.Entropy.Base64Threshold
+	}
+	if o.Entropy.HexThreshold != 0 {
+		c.Entropy.HexThreshold = o.Entropy.HexThreshold
+	}
+
+	if o.Decoding.Enabled != nil {
+		c.Decoding.Enabled = o.Decoding.Enabled
+	}
+	if o.Decoding.MinLength != 0 {
+		c.Decoding.MinLength = o.Decoding.MinLength
+	}
+
+	if o.Filters.Enabled != nil {
+		c.Filters.Enabled = o.Filters.Enabled
+	}
+	c.Filters.Placeholders = append(c.Filters.Placeholders, o.Filters.Placeholders...)
+	c.Filters.References = append(c.Filters.References, o.Filters.References...)
+	if o.Filters.MinEntropy != 0 {
+		c.Filters.MinEntropy = o.Filters.MinEntropy
+	}
+	if o.Filters.Debug {
+		c.Filters.Debug = true
+	}
+
+	// later patterns take precedence, so defaults can be negated
+	c.Confidence.LowPaths = append(c.Confidence.LowPaths, o.Confidence.LowPaths...)
+
+	if o.Verification.Enabled != nil {
+		c.Verification.Enabled = o.Verification.Enabled
+	}
+	if o.Verification.Timeout != 0 {
+		c.Verification.Timeout = o.Verification.Timeout
+	}
+	if o.Verification.RateLimit != 0 {
+		c.Verification.RateLimit = o.Verification.RateLimit
+	}
+	if len(o.Verification.Verifiers) > 0 && c.Verification.Verifiers == nil {
+		c.Verification.Verifiers = make(map[string]*VerifierSettings)
+	}
+	for name, settings := range o.Verification.Verifiers {
+		c.Verification.Verifiers[name] = settings
+	}
+
+	if o.Limits.MaxFileSize != 0 {
+		c.Limits.MaxFileSize = o.Limits.MaxFileSize
+	}
+	if o.Limits.MaxLineLength != 0 {
+		c.Limits.MaxLineLength = o.Limits.MaxLineLength
+	}
+	if o.Limits.MaxArchiveDepth != 0 {
+		c.Limits.MaxArchiveDepth = o.Limits.MaxArchiveDepth
+	}
+	if o.Limits.MaxArchiveSize != 0 {
+		c.Limits.MaxArchiveSize = o.Limits.MaxArchiveSize
+	}
+}
+
+// Helper function to validate every rule and prepare the lookup
+// structures used while scanning.
+func (c *Config) compile() error {
+	c.ruleIndex = make(map[string]*RuleDefinition)
+	for _, rd := range c.Rules {
+		if err := rd.compile(); err != nil {
+			return fmt.Errorf("rule %v: %v", rd.Id, err.Error())
+		}
+		c.ruleIndex[rd.Id] = rd
+	}
+
+	if c.Entropy.MinLength < 1 {
+		return errors.New("entropy: minLength must be positive")
+	}
+	if c.Entropy.Base64Threshold <= 0 || c.Entropy.Base64Threshold > 6 {
+		return errors.New("entropy: base64Threshold must be between 0 and 6")
+	}
+	if c.Entropy.HexThreshold <= 0 || c.Entropy.HexThreshold > 4 {
+		return errors.New("entropy: hexThreshold must be between 0 and 4")
+	}
+
+	if c.Decoding.MinLength < minDecodeLength {
+		return fmt.Errorf("decoding: minLength must be at least %v", minDecodeLength)
+	}
+
+	if err := c.Filters.compile(); err != nil {
+		return fmt.Errorf("filters: %v", err.Error())
+	}
+
+	c.Confidence.compile()
+
+	if err := c.Verification.compile(); err != nil {
+		return fmt.Errorf("verification: %v", err.Error())
+	}
+	for _, rd := range c.Rules {
+		if _, ok := c.Verification.verifiers[rd.Verifier]; rd.Verifier != "" && !ok {
+			return fmt.Errorf("rule %v: unknown verifier '%v'", rd.Id, rd.Verifier)
+		}
+	}
+
+	if c.Limits.MaxFileSize < 1 {
+		return errors.New("limits: maxFileSize must be positive")
+	}
+	if c.Limits.MaxLineLength < minLineLength {
+		return fmt.Errorf("limits: maxLineLength must be at least %v", minLineLength)
+	}
+	if c.Limits.MaxArchiveDepth < 1 {
+		return errors.New("limits: maxArchiveDepth must be positive")
+	}
+	if c.Limits.MaxArchiveSize < 1 {
+		return errors.New("limits: maxArchiveSize must be positive")
+	}
+
+	return nil
+}
+
+// Rule returns the definition of the rule with the given id, or nil
+// if no such rule exists.
+func (c *Config) Rule(id string) *RuleDefinition {
+	return c.ruleIndex[id]
+}
+
+// EnabledRules returns the list of rules that are turned on.
+func (c *Config) EnabledRules() []*RuleDefinition {
+	rules := make([]*RuleDefinition, 0, len(c.Rules))
+	for _, rd := range c.Rules {
+		if rd.IsEnabled() {
+			rules = append(rules, rd)
+		}
+	}
+
+	return rules
+}
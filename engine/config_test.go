@@ -0,0 +1,150 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+)
+
+func TestDefaultConfigContainsKeywordRules(t *testing.T) {
+	cfg := engine.DefaultConfig()
+
+	for _, id := range []string{"G001", "G002"} {
+		rd := cfg.Rule(id)
+		if rd == nil {
+			t.Fatalf("Expected rule %v in the default rule pack.\n", id)
+		}
+		if !rd.IsEnabled() {
+			t.Errorf("Expected rule %v to be enabled by default.\n", id)
+		}
+		if rd.Severity != "HIGH" {
+			t.Errorf("Expected rule %v severity to be HIGH. Got %v\n", id, rd.Severity)
+		}
+	}
+}
+
+func TestParseConfigTunesExistingRule(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte(`
+rules:
+  - id: G002
+    severity: low
+    files: ["*.go"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	rd := cfg.Rule("G002")
+	if rd.Severity != "LOW" {
+		t.Errorf("Expected severity to be LOW. Got %v\n", rd.Severity)
+	}
+	if rd.Description != "Hard-coded secret - public key" {
+		t.Errorf("Expected description to be kept. Got '%v'\n", rd.Description)
+	}
+	if !rd.AppliesTo("/src/main.go") || rd.AppliesTo("/src/main.py") {
+		t.Errorf("Expected rule to apply to go files only.\n")
+	}
+}
+
+func TestParseConfigDisablesRule(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte(`
+rules:
+  - id: G001
+    enabled: false
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	for _, rd := range cfg.EnabledRules() {
+		if rd.Id == "G001" {
+			t.Errorf("Expected rule G001 to be disabled.\n")
+		}
+	}
+}
+
+func TestParseConfigAddsNewRule(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte(`
+rules:
+  - id: C001
+    description: Internal service token
+    severity: MEDIUM
+    regex: 'svc_tok_(?P<secret>[0-9a-f]{16})'
+    keywords: [svc_tok_]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	if rd := cfg.Rule("C001"); rd == nil || !rd.IsEnabled() {
+		t.Errorf("Expected new rule C001 to be enabled.\n")
+	}
+	if cfg.Rule("G001") == nil {
+		t.Errorf("Expected default rules to be kept.\n")
+	}
+}
+
+func TestParseConfigAppendsExcludes(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte("excludes: ['!vendor/', 'third_party/']\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	defaults := engine.DefaultConfig().Excludes
+	if len(cfg.Excludes) != len(defaults)+2 {
+		t.Fatalf("Expected %v exclude patterns. Got %v\n", len(defaults)+2, len(cfg.Excludes))
+	}
+
+	if cfg.Excludes[0] != "vendor/" || cfg.Excludes[len(cfg.Excludes)-1] != "third_party/" {
+		t.Errorf("Expected rule file patterns to follow the defaults. Got %v\n", cfg.Excludes)
+	}
+}
+
+func TestParseConfigRejectsInvalidRules(t *testing.T) {
+	packs := map[string]string{
+		"bad yaml":        "rules: [",
+		"missing id":      "rules:\n  - description: x\n    severity: LOW\n    regex: x\n",
+		"duplicate id":    "rules:\n  - id: C1\n    description: x\n    severity: LOW\n    regex: x\n  - id: C1\n",
+		"no regex":        "rules:\n  - id: C1\n    description: x\n    severity: LOW\n",
+		"bad regex":       "rules:\n  - id: C1\n    description: x\n    severity: LOW\n    regex: '('\n",
+		"bad severity":    "rules:\n  - id: C1\n    description: x\n    severity: URGENT\n    regex: x\n",
+		"bad file glob":   "rules:\n  - id: C1\n    description: x\n    severity: LOW\n    regex: x\n    files: ['[']\n",
+		"bad file size":   "limits:\n  maxFileSize: -1\n",
+		"bad line length": "limits:\n  maxLineLength: 10\n",
+		"short decoding":  "decoding:\n  minLength: 4\n",
+		"bad reference":   "filters:\n  references: ['(']\n",
+		"bad confidence":  "rules:\n  - id: G001\n    confidence: certain\n",
+		"bad verifier":    "rules:\n  - id: G001\n    verifier: nowhere\n",
+		"no base url":     "verification:\n  verifiers:\n    github:\n      baseUrl: ''\n",
+		"no rate limit":   "verification:\n  rateLimit: -1\n",
+		"bad min entropy": "filters:\n  minEntropy: 7\n",
+	}
+
+	for name, pack := range packs {
+		if _, err := engine.ParseConfig([]byte(pack)); err == nil {
+			t.Errorf("Expected rule pack with %v to be rejected.\n", name)
+		}
+	}
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte("rules:\n  - id: G001\n    enabled: false\n"), 0644); err != nil {
+		t.Fatalf("Could not write rule file: %v\n", err.Error())
+	}
+
+	cfg, err := engine.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load rule file: %v\n", err.Error())
+	}
+
+	if cfg.Rule("G001").IsEnabled() {
+		t.Errorf("Expected rule G001 to be disabled.\n")
+	}
+
+	if _, err := engine.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Expected failure for missing rule file.\n")
+	}
+}
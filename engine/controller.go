@@ -19,14 +19,33 @@ type Controller struct {
 }
 
 type Job struct {
-	Id     string
-	Repo   *models.RepositoryInfo
-	Result chan *JobUpdate
+	Id         string
+	Repo       *models.RepositoryInfo
+	Request    *models.ScanRequest
+	Baseline   *Baseline
+	Credential *models.CredentialInfo
+	Archive    string
+	Result     chan *JobUpdate
+}
+
+// Optional settings for a job added to the controller queue.
+type JobOptions struct {
+	Request    *models.ScanRequest
+	Baseline   *Baseline
+	Credential *models.CredentialInfo
+	// path of the uploaded archive scanned by archive repositories
+	Archive string
 }
 
 type JobUpdate struct {
 	Status   string
 	Findings []*models.FindingsInfo
+	Skipped  []*models.SkippedFile
+	Commit   string
+	Ref      string
+	Reason   string
+	// hash of the rules and excludes of the scan, see ConfigHash
+	ConfigHash string
 }
 
 type ScanHandler interface {
@@ -106,12 +125,29 @@ func (c *Controller) Stop() {
 // Job struct containing the identifier for the queued job, as well as
 // the results channel where the output will be sent.
 func (c *Controller) AddJob(ri *models.RepositoryInfo) *Job {
+	return c.AddJobWithOptions(ri, JobOptions{})
+}
+
+// Same as AddJob, but allows the caller to control how the job is
+// executed. Unset options use their default values.
+func (c *Controller) AddJobWithOptions(ri *models.RepositoryInfo, opts JobOptions) *Job {
 	log.Printf("Received request to scan '%v'\n", ri.Name)
 
+	req := models.DefaultScanRequest()
+	if opts.Request != nil {
+		req = opts.Request.Clone()
+	}
+
 	job := Job{
-		Id:     <-c.nextJobId,
-		Repo:   ri.Clone(),
-		Result: make(chan *JobUpdate),
+		Id:       <-c.nextJobId,
+		Repo:     ri.Clone(),
+		Request:  req,
+		Baseline: opts.Baseline,
+		Archive:  opts.Archive,
+		Result:   make(chan *JobUpdate),
+	}
+	if opts.Credential != nil {
+		job.Credential = opts.Credential.Clone()
 	}
 	go func() { c.Incoming <- &job }()
 	return &job
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// User name sent with credentials that do not set one. Hosting
+// services accept any user name along with an access token, and use
+// git for ssh access.
+const defaultCredentialUser = "git"
+
+// ValidateCredential checks that the credential holds what its type
+// requires: a token, a password, or a private key that can be
+// decrypted with its passphrase. The known_hosts entries of ssh
+// credentials must be well formed. Returns nil if the credential can
+// be used to clone repositories.
+func ValidateCredential(cred *models.CredentialInfo) error {
+	switch cred.Type_ {
+	case "token":
+		if cred.Token == "" {
+			return errors.New("missing token")
+		}
+	case "basic":
+		if cred.Password == "" {
+			return errors.New("missing password")
+		}
+	case "ssh":
+		if cred.PrivateKey == "" {
+			return errors.New("missing private key")
+		}
+	default:
+		return fmt.Errorf("unknown credential type '%v'", cred.Type_)
+	}
+
+	_, err := authMethod(cred)
+	return err
+}
+
+// Helper function to build the go-git authentication method of a
+// credential. Returns nil if there is no credential, in which case
+// the repository is cloned anonymously.
+func authMethod(cred *models.CredentialInfo) (transport.AuthMethod, error) {
+	if cred == nil {
+		return nil, nil
+	}
+
+	user := cred.Username
+	if user == "" {
+		user = defaultCredentialUser
+	}
+
+	switch cred.Type_ {
+	case "token":
+		return &githttp.BasicAuth{Username: user, Password: cred.Token}, nil
+	case "basic":
+		return &githttp.BasicAuth{Username: user, Password: cred.Password}, nil
+	case "ssh":
+		keys, err := gitssh.NewPublicKeys(user, []byte(cred.PrivateKey), cred.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err.Error())
+		}
+
+		// without entries of its own, the credential relies on the
+		// known_hosts files of the system
+		if cred.KnownHosts != "" {
+			path, err := writeKnownHosts(cred.KnownHosts)
+			if err != nil {
+				return nil, err
+			}
+			keys.HostKeyCallback, err = gitssh.NewKnownHostsCallback(path)
+			os.Remove(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid known hosts: %v", err.Error())
+			}
+		}
+		return keys, nil
+	}
+
+	return nil, fmt.Errorf("unknown credential type '%v'", cred.Type_)
+}
+
+// Helper function to write known_hosts entries to a temporary file,
+// since they can only be loaded from files. The caller removes the
+// file once loaded.
+func writeKnownHosts(entries string) (string, error) {
+	f, err := ioutil.TempFile("", "known_hosts")
+	if err != nil {
+		return "", fmt.Errorf("cannot write known hosts: %v", err.Error())
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(entries + "\n"); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("cannot write known hosts: %v", err.Error())
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,200 @@
+package engine_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// Helper function to serve the repositories under the root directory
+// with git http-backend, requiring basic authentication with the given
+// user name and password.
+func makeGitHttpServer(t *testing.T, root, user, password string) *httptest.Server {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not available")
+	}
+
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCheckoutRepositoryWithCredential(t *testing.T) {
+	src, hashes := makeGitRepo(t, []map[string]string{{"a.txt": "one\n"}})
+	server := makeGitHttpServer(t, filepath.Dir(src), "git", "s3cret")
+	url := server.URL + "/" + filepath.Base(src)
+
+	tests := []struct {
+		cred *models.CredentialInfo
+		ok   bool
+	}{
+		{nil, false},
+		{&models.CredentialInfo{Type_: "token", Token: "s3cret"}, true},
+		{&models.CredentialInfo{Type_: "token", Token: "wrong"}, false},
+		{&models.CredentialInfo{Type_: "basic", Username: "git", Password: "s3cret"}, true},
+		{&models.CredentialInfo{Type_: "basic", Username: "someone", Password: "s3cret"}, false},
+	}
+
+	for i, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "checkout")
+		co, err := engine.CheckoutRepository(url, &engine.CheckoutOptions{Branch: "master", Credential: tt.cred}, dir)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("Expected checkout %v to fail.\n", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Checkout %v failed: %v\n", i, err.Error())
+		} else if co.Commit != hashes[0] {
+			t.Errorf("Expected commit %v for checkout %v. Got %v\n", hashes[0], i, co.Commit)
+		}
+	}
+}
+
+func TestCheckoutSubmodulesWithCredential(t *testing.T) {
+	lib, libHashes := makeGitRepo(t, []map[string]string{{"lib.go": "package lib\n"}})
+	root := filepath.Dir(lib)
+	private := makeGitHttpServer(t, root, "git", "s3cret")
+
+	// the other server takes anonymous requests and records whether a
+	// credential was sent along
+	gitPath, _ := exec.LookPath("git")
+	authorized := false
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			authorized = true
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer public.Close()
+
+	tests := []struct {
+		server string
+		host   string
+	}{
+		{private.URL, "the same host"},
+		{strings.Replace(public.URL, "127.0.0.1", "localhost", 1), "another host"},
+	}
+
+	cred := &models.CredentialInfo{Type_: "token", Token: "s3cret"}
+	for _, tt := range tests {
+		super := makeSuperRepoWithUrl(t, tt.server+"/"+filepath.Base(lib), libHashes[0])
+		url := private.URL + "/" + filepath.Base(super)
+
+		dir := filepath.Join(t.TempDir(), "checkout")
+		_, err := engine.CheckoutRepository(url, &engine.CheckoutOptions{Branch: "master", SubmoduleDepth: 1, Credential: cred}, dir)
+		if err != nil {
+			t.Errorf("Checkout with a submodule on %v failed: %v\n", tt.host, err.Error())
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "third_party", "lib", "lib.go")); err != nil {
+			t.Errorf("Expected the submodule on %v to be checked out. Got %v\n", tt.host, err.Error())
+		}
+	}
+
+	if authorized {
+		t.Errorf("Expected the credential not to be sent to another host.\n")
+	}
+}
+
+func TestCheckoutFileRemoteWithCredential(t *testing.T) {
+	src, hashes := makeGitRepo(t, []map[string]string{{"a.txt": "one\n"}})
+	cred := &models.CredentialInfo{Type_: "token", Token: "unused"}
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	co, err := engine.CheckoutRepository("file://"+src, &engine.CheckoutOptions{Branch: "master", Credential: cred}, dir)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v\n", err.Error())
+	}
+
+	if co.Commit != hashes[0] {
+		t.Errorf("Expected commit %v. Got %v\n", hashes[0], co.Commit)
+	}
+}
+
+// Helper function to generate a PEM encoded RSA private key,
+// encrypted with the passphrase if there is one.
+func makePrivateKey(t *testing.T, passphrase string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v\n", err.Error())
+	}
+
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if passphrase != "" {
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatalf("Could not encrypt key: %v\n", err.Error())
+		}
+	}
+
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestValidateCredential(t *testing.T) {
+	key := makePrivateKey(t, "")
+	encrypted := makePrivateKey(t, "open sesame")
+	knownHosts := "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+	tests := []struct {
+		cred models.CredentialInfo
+		ok   bool
+	}{
+		{models.CredentialInfo{Type_: "token", Token: "abc"}, true},
+		{models.CredentialInfo{Type_: "token"}, false},
+		{models.CredentialInfo{Type_: "basic", Username: "dev", Password: "abc"}, true},
+		{models.CredentialInfo{Type_: "basic", Username: "dev"}, false},
+		{models.CredentialInfo{Type_: "ssh", PrivateKey: key}, true},
+		{models.CredentialInfo{Type_: "ssh", PrivateKey: key, KnownHosts: knownHosts}, true},
+		{models.CredentialInfo{Type_: "ssh", PrivateKey: key, KnownHosts: "github.com not-a-key"}, false},
+		{models.CredentialInfo{Type_: "ssh", PrivateKey: encrypted, Passphrase: "open sesame"}, true},
+		{models.CredentialInfo{Type_: "ssh", PrivateKey: encrypted, Passphrase: "wrong"}, false},
+		{models.CredentialInfo{Type_: "ssh", PrivateKey: "not a key"}, false},
+		{models.CredentialInfo{Type_: "ssh"}, false},
+		{models.CredentialInfo{Type_: "oauth", Token: "abc"}, false},
+	}
+
+	for i, tt := range tests {
+		err := engine.ValidateCredential(&tt.cred)
+		if tt.ok && err != nil {
+			t.Errorf("Expected credential %v to be valid. Got %v\n", i, err.Error())
+		} else if !tt.ok && err == nil {
+			t.Errorf("Expected credential %v to be invalid.\n", i)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Shortest encoded run that can be configured. Shorter runs decode to
+// too few characters to hold a secret.
+const minDecodeLength = 8
+
+// Encodings of the runs decoded by the decoding analyzer.
+const (
+	encodingBase64 = "base64"
+	encodingHex    = "hex"
+)
+
+// Analyzer that decodes base64 and hex runs and runs the regex and
+// private key rules again on the line with the run replaced by the
+// decoded text. Only one level of encoding is decoded.
+type decodingAnalyzer struct {
+	fs           *fileScan
+	regexRules   []*RuleDefinition
+	plainKey     *RuleDefinition
+	encryptedKey *RuleDefinition
+	settings     *DecodingSettings
+}
+
+func newDecodingAnalyzer(fs *fileScan, regexRules []*RuleDefinition, plainKey, encryptedKey *RuleDefinition, settings *DecodingSettings) *decodingAnalyzer {
+	return &decodingAnalyzer{
+		fs:           fs,
+		regexRules:   regexRules,
+		plainKey:     plainKey,
+		encryptedKey: encryptedKey,
+		settings:     settings,
+	}
+}
+
+func (da *decodingAnalyzer) scanLine(line string, num int) {
+	min := da.settings.MinLength
+	if len(line) < min || !hasBase64Run(line, min) {
+		return
+	}
+
+	for _, loc := range base64Run.FindAllStringIndex(line, -1) {
+		run := line[loc[0]:loc[1]]
+		if len(run) < min {
+			continue
+		}
+
+		decoded, encoding := decodeRun(run)
+		if decoded == "" {
+			continue
+		}
+
+		// the secret may need the text around the run to be recognized,
+		// as in 'password: aHVudGVyMjI='
+		text := line[:loc[0]] + decoded + line[loc[1]:]
+		for _, m := range da.scanDecoded(text) {
+			if int(m.end.offset) <= loc[0] || int(m.begin.offset) >= loc[0]+len(decoded) {
+				// found in the text around the run
+				continue
+			}
+
+			m.begin, m.end = da.fs.position(num, loc[0]), da.fs.position(num, loc[1])
+			m.context, m.encoded, m.encoding = line, run, encoding
+			da.fs.report(m)
+		}
+	}
+}
+
+func (da *decodingAnalyzer) finish() {}
+
+// Helper function to run the rules over the given text, which may span
+// several lines. The offsets of the matches are relative to the text.
+func (da *decodingAnalyzer) scanDecoded(text string) []*match {
+	inner := &fileScan{
+		finder:    da.fs.finder,
+		path:      da.fs.path,
+		analyzers: make([]lineAnalyzer, 0),
+	}
+
+	if len(da.regexRules) > 0 {
+		inner.analyzers = append(inner.analyzers, newRegexAnalyzer(inner, da.regexRules))
+	}
+	if da.plainKey != nil || da.encryptedKey != nil {
+		inner.analyzers = append(inner.analyzers, newPrivateKeyAnalyzer(inner, da.plainKey, da.encryptedKey))
+	}
+
+	var offset int64
+	for i, line := range strings.Split(text, "\n") {
+		inner.scanLine(strings.TrimSuffix(line, "\r"), i+1, offset)
+		offset += int64(len(line)) + 1
+	}
+	inner.finishAnalyzers()
+
+	return inner.matches
+}
+
+// Helper function to decode a run of base64 or hex characters. Returns
+// the decoded text and its encoding, or empty strings if the run does
+// not decode to text.
+func decodeRun(run string) (string, string) {
+	if len(run)%2 == 0 && isHex(run) {
+		if b, err := hex.DecodeString(run); err == nil && isText(b) {
+			return string(b), encodingHex
+		}
+	}
+
+	// padding is optional, and the alphabet of the run tells which
+	// variant of base64 is used
+	trimmed := strings.TrimRight(run, "=")
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(trimmed); err == nil && isText(b) {
+			return string(b), encodingBase64
+		}
+	}
+
+	return "", ""
+}
+
+// Helper function to check whether decoded bytes are printable text.
+// Most runs that are not encoded text, such as identifiers or hashes,
+// decode to binary data.
+func isText(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,26 +1,566 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/UserProblem/reposcanner/models"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
+// Name of the file describing the submodules of a repository.
+const gitmodulesFile = ".gitmodules"
+
+// What to check out of a repository. The Ref can be a branch or tag
+// name, or a full reference name such as refs/pull/1/head, and
+// defaults to the Branch. If a Commit is given, it is checked out
+// instead of the head of the ref, and must be reachable from the ref,
+// or from any branch or tag if there is no ref. Submodules are
+// initialized up to SubmoduleDepth levels of nesting. The Credential,
+// if any, authenticates the repository and its submodules.
+type CheckoutOptions struct {
+	Branch         string
+	Ref            string
+	Commit         string
+	SubmoduleDepth int
+	Credential     *models.CredentialInfo
+
+	// Number of commits fetched from the head of the ref, 0 to fetch
+	// the whole history. Ignored when a Commit is given, since it can
+	// be anywhere in the history.
+	Depth int
+
+	// Paths checked out, using the gitignore syntax. Every path is
+	// checked out if empty.
+	SparsePaths []string
+
+	// Look for a Commit given without a ref in the Branch only,
+	// instead of every branch and tag.
+	SingleBranch bool
+
+	// Largest size in bytes the checkout directory can grow to,
+	// 0 for no limit.
+	MaxSize int64
+
+	// Longest time the checkout can take, 0 for no limit.
+	Timeout time.Duration
+}
+
+// The reference and commit that were checked out.
+type Checkout struct {
+	Ref    string
+	Commit string
+}
+
 func CloneRepository(url, branch string, checkoutDir string) error {
-	_, err := git.PlainClone(checkoutDir, false, &git.CloneOptions{
-		URL:           url,
-		ReferenceName: plumbing.NewBranchReferenceName(branch),
+	return CloneRepositoryWithSubmodules(url, branch, checkoutDir, 0)
+}
+
+// Clone the repository and initialize its submodules, recursing into
+// nested submodules up to the given depth. A depth of 0 leaves the
+// submodules out.
+func CloneRepositoryWithSubmodules(url, branch string, checkoutDir string, depth int) error {
+	_, err := CheckoutRepository(url, &CheckoutOptions{Branch: branch, SubmoduleDepth: depth}, checkoutDir)
+	return err
+}
+
+// CheckoutRepository fetches the ref of the repository at the given
+// url and checks it out, or the commit of the options if there is one.
+// Returns the full name of the ref and the commit that were checked
+// out, or nil and an error on failure. Exceeding the size or time
+// limit of the options is reported as such.
+func CheckoutRepository(url string, opts *CheckoutOptions, checkoutDir string) (*Checkout, error) {
+	if opts.SubmoduleDepth < 0 || opts.SubmoduleDepth > MaxSubmoduleDepth {
+		return nil, fmt.Errorf("submodule depth must be between 0 and %v", MaxSubmoduleDepth)
+	}
+
+	return withCloneLimits(opts, []string{checkoutDir}, func(ctx context.Context) (*Checkout, error) {
+		return checkoutRepository(ctx, url, opts, checkoutDir)
 	})
+}
+
+// Helper function to run a checkout within the size and time limits
+// of the options. The size limit applies to what the checkout adds to
+// the given directories together.
+func withCloneLimits(opts *CheckoutOptions, dirs []string, checkout func(context.Context) (*Checkout, error)) (*Checkout, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), opts.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	var guard *sizeGuard
+	if opts.MaxSize > 0 {
+		guard = newSizeGuard(dirs, opts.MaxSize)
+		go guard.watch(ctx, cancel)
+	}
+
+	co, err := checkout(ctx)
+	if err == nil && guard != nil {
+		err = guard.check()
+	}
 
 	if err != nil {
-		return fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+		if guard != nil && guard.isExceeded() {
+			return nil, fmt.Errorf("repository exceeds the maximum size of %v bytes", opts.MaxSize)
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("clone timed out after %v", opts.Timeout)
+		}
+		return nil, err
 	}
+
+	return co, nil
+}
+
+// Helper function to fetch and check out the repository, giving up
+// once the context is done.
+func checkoutRepository(ctx context.Context, url string, opts *CheckoutOptions, checkoutDir string) (*Checkout, error) {
+	auth, err := authMethod(opts.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+	}
+
+	repo, err := git.PlainInit(checkoutDir, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+	}
+
+	refName, hash, err := fetchRevision(ctx, repo, url, opts, auth, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkoutWorktree(ctx, repo, url, opts, refName, hash, auth)
+}
+
+// Helper function to fetch the ref or commit of the options from the
+// remote into the repository. A mirror fetches every branch and tag
+// with their whole history, as well as the requested ref. Returns the
+// full name of the ref, empty if a commit was requested without one,
+// and the commit to check out.
+func fetchRevision(ctx context.Context, repo *git.Repository, url string, opts *CheckoutOptions, auth transport.AuthMethod, mirror bool) (plumbing.ReferenceName, plumbing.Hash, error) {
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err == git.ErrRemoteNotFound {
+		remote, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	}
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+	}
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+	}
+
+	name := opts.Ref
+	if name == "" {
+		name = opts.Branch
+	}
+
+	var refName plumbing.ReferenceName
+	if opts.Ref != "" || opts.Commit == "" || opts.SingleBranch {
+		if refName = resolveRef(refs, name); refName == "" {
+			return "", plumbing.ZeroHash, fmt.Errorf("failed to clone url %v: cannot resolve ref '%v'", url, name)
+		}
+	}
+
+	// a commit without a ref is looked for in every branch and tag
+	allSpecs := []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+	specs := []config.RefSpec{config.RefSpec("+" + refName + ":" + refName)}
+	if mirror && (refName == "" || refName.IsBranch() || refName.IsTag()) {
+		specs = allSpecs
+	} else if mirror {
+		specs = append(allSpecs, specs...)
+	} else if refName == "" {
+		specs = allSpecs
+	}
+
+	depth := opts.Depth
+	if opts.Commit != "" || mirror {
+		depth = 0
+	}
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{RefSpecs: specs, Depth: depth, Tags: git.NoTags, Auth: auth})
+	if err == git.NoErrAlreadyUpToDate {
+		err = nil
+	}
+	if err == nil && mirror {
+		err = pruneRefs(repo, refs)
+	}
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+	}
+
+	rev := plumbing.Revision(opts.Commit)
+	if opts.Commit == "" {
+		rev = plumbing.Revision(refName)
+	}
+
+	hash, err := repo.ResolveRevision(rev)
+	if err == nil {
+		_, err = repo.CommitObject(*hash)
+	}
+
+	// a mirror holds more than the requested ref, so the commit must
+	// be checked against it
+	if err == nil && mirror && opts.Commit != "" && !reachable(repo, *hash, refs, refName) {
+		err = plumbing.ErrObjectNotFound
+	}
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to clone url %v: cannot find commit '%v'", url, rev)
+	}
+
+	return refName, *hash, nil
+}
+
+// Helper function to remove the branches and tags of a mirror that no
+// longer exist on the remote.
+func pruneRefs(repo *git.Repository, refs []*plumbing.Reference) error {
+	listed := make(map[plumbing.ReferenceName]bool)
+	for _, ref := range refs {
+		listed[ref.Name()] = true
+	}
+
+	iter, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	var stale []plumbing.ReferenceName
+	iter.ForEach(func(ref *plumbing.Reference) error {
+		if (ref.Name().IsBranch() || ref.Name().IsTag()) && !listed[ref.Name()] {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+
+	for _, name := range stale {
+		if err := repo.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// Helper function to check whether the commit is part of the history
+// of the ref, or of any branch or tag of the remote if there is no
+// ref.
+func reachable(repo *git.Repository, hash plumbing.Hash, refs []*plumbing.Reference, refName plumbing.ReferenceName) bool {
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		return false
+	}
+
+	for _, ref := range refs {
+		if refName != "" && ref.Name() != refName {
+			continue
+		}
+		if refName == "" && !ref.Name().IsBranch() && !ref.Name().IsTag() {
+			continue
+		}
+
+		head, err := repo.ResolveRevision(plumbing.Revision(ref.Name()))
+		if err != nil {
+			continue
+		}
+		if *head == hash {
+			return true
+		}
+
+		if tip, err := repo.CommitObject(*head); err == nil {
+			if ok, err := c.IsAncestor(tip); err == nil && ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Helper function to check out the commit in the worktree of the
+// repository, then initialize its submodules.
+func checkoutWorktree(ctx context.Context, repo *git.Repository, url string, opts *CheckoutOptions, refName plumbing.ReferenceName, hash plumbing.Hash, auth transport.AuthMethod) (*Checkout, error) {
+	name := opts.Ref
+	if name == "" {
+		name = opts.Branch
+	}
+
+	// branches stay checked out by name unless a commit was requested
+	head := plumbing.NewHashReference(plumbing.HEAD, hash)
+	checkout := &git.CheckoutOptions{Hash: hash}
+	if opts.Commit == "" && refName.IsBranch() {
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, refName)
+		checkout = &git.CheckoutOptions{Branch: refName}
+	}
+
+	wt, err := repo.Worktree()
+	if err == nil {
+		if len(opts.SparsePaths) > 0 {
+			err = sparseCheckout(ctx, repo, wt, head, sparseMatcher(opts.SparsePaths))
+		} else {
+			err = wt.Checkout(checkout)
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check out %v: %v", name, err.Error())
+	}
+
+	if opts.SubmoduleDepth > 0 {
+		keep := func(string) bool { return true }
+		if len(opts.SparsePaths) > 0 {
+			m := sparseMatcher(opts.SparsePaths)
+			keep = func(path string) bool { return m.Match(strings.Split(path, "/"), true) }
+		}
+
+		if err := updateSubmodules(ctx, wt, opts.SubmoduleDepth, url, auth, keep); err != nil {
+			return nil, fmt.Errorf("failed to update submodules: %v", err.Error())
+		}
+	}
+
+	return &Checkout{Ref: string(refName), Commit: hash.String()}, nil
+}
+
+// Helper function to return the full name of the listed reference
+// matching the given name, trying branches first, then tags, then
+// other references such as pull/1/head. Returns an empty name if
+// no reference matches.
+func resolveRef(refs []*plumbing.Reference, name string) plumbing.ReferenceName {
+	candidates := []string{name, "refs/heads/" + name, "refs/tags/" + name, "refs/" + name}
+	if strings.HasPrefix(name, "refs/") {
+		candidates = candidates[:1]
+	}
+
+	for _, c := range candidates {
+		for _, ref := range refs {
+			if string(ref.Name()) == c && ref.Name() != plumbing.HEAD {
+				return ref.Name()
+			}
+		}
+	}
+
+	return ""
+}
+
+// Helper function to initialize the submodules of the checked out
+// tree that are kept, recursing into nested submodules up to the
+// given depth. The credential of the repository at the given url is
+// only sent to submodules on the same host.
+func updateSubmodules(ctx context.Context, wt *git.Worktree, depth int, url string, auth transport.AuthMethod, keep func(string) bool) error {
+	subs, err := wt.Submodules()
+	if err != nil {
+		return err
+	}
+
+	for _, sm := range subs {
+		if !keep(sm.Config().Path) {
+			continue
+		}
+
+		if err := sm.Init(); err != nil && err != git.ErrSubmoduleAlreadyInitialized {
+			return err
+		}
+
+		// relative urls are resolved against the remote of the parent
+		r, err := sm.Repository()
+		if err != nil {
+			return err
+		}
+		remote, err := r.Remote(git.DefaultRemoteName)
+		if err != nil {
+			return err
+		}
+
+		// nested submodules are updated here rather than by go-git,
+		// which would send them the same credential
+		err = sm.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+			RecurseSubmodules: git.NoRecurseSubmodules,
+			Auth:              submoduleAuth(url, remote.Config().URLs[0], auth),
+		})
+		if err != nil {
+			return err
+		}
+
+		if depth > 1 {
+			subWt, err := r.Worktree()
+			if err != nil {
+				return err
+			}
+			all := func(string) bool { return true }
+			if err := updateSubmodules(ctx, subWt, depth-1, url, auth, all); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Helper function to return the credential sent to a submodule. The
+// urls of submodules come from the scanned repository, so the
+// credential of the repository is only sent to submodules with the
+// same scheme and host, and others are cloned anonymously.
+func submoduleAuth(parent, url string, auth transport.AuthMethod) transport.AuthMethod {
+	if auth == nil {
+		return nil
+	}
+
+	p, err := transport.NewEndpoint(parent)
+	if err != nil {
+		return nil
+	}
+	s, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil
+	}
+
+	if p.Protocol != s.Protocol || p.Host != s.Host || p.Port != s.Port {
+		return nil
+	}
+	return auth
+}
+
+// Helper function to compile the sparse path patterns.
+func sparseMatcher(paths []string) gitignore.Matcher {
+	patterns := make([]gitignore.Pattern, 0, len(paths))
+	for _, p := range paths {
+		patterns = appendPattern(patterns, p)
+	}
+	return gitignore.NewMatcher(patterns)
+}
+
+// Helper function to check out the files of the commit HEAD points to
+// that match the sparse paths, as well as .gitmodules so submodules
+// can be found. The index lists every file of the commit, as with a
+// full checkout.
+func sparseCheckout(ctx context.Context, repo *git.Repository, wt *git.Worktree, head *plumbing.Reference, m gitignore.Matcher) error {
+	hash := head.Hash()
+	if head.Type() == plumbing.SymbolicReference {
+		ref, err := repo.Reference(head.Target(), true)
+		if err != nil {
+			return err
+		}
+		hash = ref.Hash()
+	}
+
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	idx := &index.Index{Version: 2}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+
+		e := idx.Add(name)
+		e.Hash = entry.Hash
+		e.Mode = entry.Mode
+
+		isSubmodule := entry.Mode == filemode.Submodule
+		if name != gitmodulesFile && !m.Match(strings.Split(name, "/"), isSubmodule) {
+			continue
+		}
+
+		if isSubmodule {
+			err = wt.Filesystem.MkdirAll(name, 0755)
+		} else {
+			err = writeBlob(repo, wt, name, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(idx.Entries, func(i, j int) bool { return idx.Entries[i].Name < idx.Entries[j].Name })
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		return err
+	}
+
+	return repo.Storer.SetReference(head)
+}
+
+// Helper function to write the contents of a file of the tree to the
+// worktree.
+func writeBlob(repo *git.Repository, wt *git.Worktree, name string, entry object.TreeEntry) error {
+	blob, err := repo.BlobObject(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if entry.Mode == filemode.Symlink {
+		target, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return wt.Filesystem.Symlink(string(target), name)
+	}
+
+	perm := os.FileMode(0644)
+	if entry.Mode == filemode.Executable {
+		perm = 0755
+	}
+
+	f, err := wt.Filesystem.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
 func DeleteTmpDirectory(path string) {
 	if err := os.RemoveAll(path); err != nil {
 		log.Printf("Failed to delete tmp directory %v: %v", path, err.Error())
@@ -3,11 +3,19 @@ package engine_test
 import (
 	"fmt"
 	"io/fs"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/UserProblem/reposcanner/engine"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func TestCloneRepositoryInvalidUrl(t *testing.T) {
@@ -114,3 +122,216 @@ func TestCloneRepositoryDifferentBranch(t *testing.T) {
 		}
 	}
 }
+
+// Helper function to create a repository with two commits on its
+// master branch, a tag on the first one and a pull request ref holding
+// a third commit. Returns the repository path and the commit hashes.
+func makeRefsRepo(t *testing.T) (string, []string) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.txt": "one\n"},
+		{"a.txt": "two\n"},
+		{"a.txt": "three\n"},
+	})
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Could not open repository: %v\n", err.Error())
+	}
+
+	// the last commit is only reachable from the pull request
+	refs := []*plumbing.Reference{
+		plumbing.NewHashReference("refs/heads/master", plumbing.NewHash(hashes[1])),
+		plumbing.NewHashReference("refs/pull/7/head", plumbing.NewHash(hashes[2])),
+	}
+	for _, ref := range refs {
+		if err := repo.Storer.SetReference(ref); err != nil {
+			t.Fatalf("Could not set reference: %v\n", err.Error())
+		}
+	}
+
+	if _, err := repo.CreateTag("v1.0.0", plumbing.NewHash(hashes[0]), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "Dev Eloper", Email: "dev@example.com", When: time.Now()},
+		Message: "release",
+	}); err != nil {
+		t.Fatalf("Could not create tag: %v\n", err.Error())
+	}
+
+	return dir, hashes
+}
+
+func TestCheckoutRepositoryResolvesRefs(t *testing.T) {
+	src, hashes := makeRefsRepo(t)
+
+	tests := []struct {
+		opts   engine.CheckoutOptions
+		ref    string
+		commit string
+	}{
+		{engine.CheckoutOptions{Branch: "master"}, "refs/heads/master", hashes[1]},
+		{engine.CheckoutOptions{Branch: "master", Ref: "v1.0.0"}, "refs/tags/v1.0.0", hashes[0]},
+		{engine.CheckoutOptions{Branch: "master", Ref: "pull/7/head"}, "refs/pull/7/head", hashes[2]},
+		{engine.CheckoutOptions{Branch: "master", Ref: "refs/pull/7/head", Commit: hashes[1][:10]}, "refs/pull/7/head", hashes[1]},
+		{engine.CheckoutOptions{Branch: "master", Commit: hashes[0]}, "", hashes[0]},
+	}
+
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "checkout")
+		co, err := engine.CheckoutRepository(src, &tt.opts, dir)
+		if err != nil {
+			t.Errorf("Checkout of %v failed: %v\n", tt.opts, err.Error())
+			continue
+		}
+
+		if co.Ref != tt.ref || co.Commit != tt.commit {
+			t.Errorf("Expected %v at %v for %v. Got %v at %v\n", tt.ref, tt.commit, tt.opts, co.Ref, co.Commit)
+		}
+
+		if head, err := engine.HeadCommit(dir); err != nil || head != tt.commit {
+			t.Errorf("Expected %v to be checked out for %v. Got %v\n", tt.commit, tt.opts, head)
+		}
+	}
+}
+
+func TestCheckoutRepositoryUnknownRef(t *testing.T) {
+	src, hashes := makeRefsRepo(t)
+
+	tests := []engine.CheckoutOptions{
+		{Branch: "master", Ref: "v2.0.0"},
+		{Branch: "develop"},
+		{Branch: "master", Ref: "master", Commit: hashes[2]},
+		{Branch: "master", Commit: "0123456789abcdef0123456789abcdef01234567"},
+	}
+
+	for _, opts := range tests {
+		if _, err := engine.CheckoutRepository(src, &opts, filepath.Join(t.TempDir(), "checkout")); err == nil {
+			t.Errorf("Expected failure for %v, but error not returned.\n", opts)
+		}
+	}
+}
+
+func TestCheckoutRepositoryShallow(t *testing.T) {
+	src, hashes := makeGitRepo(t, []map[string]string{
+		{"a.txt": "one\n"},
+		{"a.txt": "two\n"},
+		{"a.txt": "three\n"},
+	})
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	co, err := engine.CheckoutRepository("file://"+src, &engine.CheckoutOptions{Branch: "master", Depth: 1}, dir)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v\n", err.Error())
+	}
+
+	if co.Commit != hashes[2] {
+		t.Errorf("Expected commit %v. Got %v\n", hashes[2], co.Commit)
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Could not open checkout: %v\n", err.Error())
+	}
+
+	if _, err := repo.CommitObject(plumbing.NewHash(hashes[1])); err == nil {
+		t.Errorf("Expected older commits to be left out of a shallow checkout.\n")
+	}
+
+	// a requested commit is looked for in the whole history
+	dir = filepath.Join(t.TempDir(), "checkout")
+	co, err = engine.CheckoutRepository("file://"+src, &engine.CheckoutOptions{Branch: "master", Commit: hashes[0], Depth: 1}, dir)
+	if err != nil || co.Commit != hashes[0] {
+		t.Errorf("Expected commit %v to be checked out. Got %v, %v\n", hashes[0], co, err)
+	}
+}
+
+func TestCheckoutRepositorySparse(t *testing.T) {
+	src, hashes := makeGitRepo(t, []map[string]string{
+		{"src/main.go": "package main\n", "docs/guide.md": "# Guide\n", "src/gen/out.go": "package gen\n"},
+	})
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	opts := &engine.CheckoutOptions{Branch: "master", SparsePaths: []string{"src/", "!src/gen/"}}
+	co, err := engine.CheckoutRepository(src, opts, dir)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v\n", err.Error())
+	}
+
+	if co.Ref != "refs/heads/master" || co.Commit != hashes[0] {
+		t.Errorf("Expected master at %v. Got %v at %v\n", hashes[0], co.Ref, co.Commit)
+	}
+
+	for name, expected := range map[string]bool{"src/main.go": true, "docs/guide.md": false, "src/gen/out.go": false} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if expected && err != nil {
+			t.Errorf("Expected %v to be checked out.\n", name)
+		} else if !expected && err == nil {
+			t.Errorf("Expected %v to be left out.\n", name)
+		}
+	}
+
+	if head, err := engine.HeadCommit(dir); err != nil || head != hashes[0] {
+		t.Errorf("Expected HEAD at %v. Got %v\n", hashes[0], head)
+	}
+}
+
+func TestCheckoutRepositorySingleBranch(t *testing.T) {
+	src, hashes := makeRefsRepo(t)
+
+	repo, err := git.PlainOpen(src)
+	if err != nil {
+		t.Fatalf("Could not open repository: %v\n", err.Error())
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", plumbing.NewHash(hashes[2]))); err != nil {
+		t.Fatalf("Could not set reference: %v\n", err.Error())
+	}
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if _, err := engine.CheckoutRepository(src, &engine.CheckoutOptions{Branch: "master", Commit: hashes[2]}, dir); err != nil {
+		t.Errorf("Expected the commit to be found in another branch: %v\n", err.Error())
+	}
+
+	dir = filepath.Join(t.TempDir(), "checkout")
+	if _, err := engine.CheckoutRepository(src, &engine.CheckoutOptions{Branch: "master", Commit: hashes[2], SingleBranch: true}, dir); err == nil {
+		t.Errorf("Expected the commit to be looked for in master only.\n")
+	}
+
+	dir = filepath.Join(t.TempDir(), "checkout")
+	co, err := engine.CheckoutRepository(src, &engine.CheckoutOptions{Branch: "master", Commit: hashes[0], SingleBranch: true}, dir)
+	if err != nil || co.Ref != "refs/heads/master" || co.Commit != hashes[0] {
+		t.Errorf("Expected commit %v of master to be checked out. Got %v, %v\n", hashes[0], co, err)
+	}
+}
+
+func TestCheckoutRepositoryMaxSize(t *testing.T) {
+	// random data does not compress, so the repository stays large
+	data := make([]byte, 512*1024)
+	rand.Read(data)
+	src, _ := makeGitRepo(t, []map[string]string{{"blob.bin": string(data)}})
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	_, err := engine.CheckoutRepository(src, &engine.CheckoutOptions{Branch: "master", MaxSize: 64 * 1024}, dir)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size of 65536 bytes") {
+		t.Errorf("Expected the size limit to be reported. Got %v\n", err)
+	}
+
+	dir = filepath.Join(t.TempDir(), "checkout")
+	if _, err := engine.CheckoutRepository(src, &engine.CheckoutOptions{Branch: "master", MaxSize: 4 * 1024 * 1024}, dir); err != nil {
+		t.Errorf("Expected the checkout to fit in the limit: %v\n", err.Error())
+	}
+}
+
+func TestCheckoutRepositoryTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	opts := &engine.CheckoutOptions{Branch: "master", Timeout: 100 * time.Millisecond}
+	_, err := engine.CheckoutRepository(server.URL+"/repo.git", opts, dir)
+	if err == nil || err.Error() != "clone timed out after 100ms" {
+		t.Errorf("Expected the timeout to be reported. Got %v\n", err)
+	}
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Matches the candidates for entropy analysis: quoted string literals
+// and unquoted values of assignments.
+var entropyCandidates = regexp.MustCompile(
+	`"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'|` + "`([^`]*)`" +
+		`|[A-Za-z0-9_.\-]+\s*(?::=|=>|[:=])\s*([^\s'"` + "`" + `;,(){}\[\]]+)`)
+
+// Matches runs of characters from the base64 alphabets, including the
+// URL-safe variant and padding.
+var base64Run = regexp.MustCompile(`[A-Za-z0-9+/_\-]+={0,2}`)
+
+// Analyzer that reports random looking tokens, which are likely to be
+// generated secrets regardless of the name they are assigned to.
+type entropyAnalyzer struct {
+	fs       *fileScan
+	rule     *RuleDefinition
+	settings *EntropySettings
+}
+
+func newEntropyAnalyzer(fs *fileScan, rd *RuleDefinition, settings *EntropySettings) *entropyAnalyzer {
+	return &entropyAnalyzer{
+		fs:       fs,
+		rule:     rd,
+		settings: settings,
+	}
+}
+
+func (ea *entropyAnalyzer) scanLine(line string, num int) {
+	if len(line) < ea.settings.MinLength || !hasBase64Run(line, ea.settings.MinLength) || !ea.rule.hasKeyword(line) {
+		return
+	}
+
+	for _, m := range entropyCandidates.FindAllStringSubmatchIndex(line, -1) {
+		// only one of the alternatives captures the candidate
+		var from, to int
+		for g := 1; g < len(m)/2; g++ {
+			if m[2*g] >= 0 {
+				from, to = m[2*g], m[2*g+1]
+				break
+			}
+		}
+		candidate := line[from:to]
+
+		// private key blocks are reported by their own analyzer
+		if strings.Contains(candidate, "PRIVATE KEY") {
+			continue
+		}
+
+		for _, t := range base64Run.FindAllStringIndex(candidate, -1) {
+			token := candidate[t[0]:t[1]]
+			if len(token) < ea.settings.MinLength || !ea.isRandom(token) {
+				continue
+			}
+
+			ea.fs.report(&match{
+				rule:    ea.rule,
+				begin:   ea.fs.position(num, from+t[0]),
+				end:     ea.fs.position(num, from+t[1]),
+				secret:  token,
+				context: line,
+			})
+		}
+	}
+}
+
+func (ea *entropyAnalyzer) finish() {}
+
+// Helper function to cheaply check whether the line holds a run of
+// base64 characters long enough to be reported, before the candidates
+// are extracted with the regular expressions.
+func hasBase64Run(line string, min int) bool {
+	run := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '+' || c == '/' || c == '_' || c == '-' || c == '=' {
+			run++
+			if run >= min {
+				return true
+			}
+		} else {
+			run = 0
+		}
+	}
+	return false
+}
+
+// Helper function to compare the entropy of the token against the
+// threshold of its character set.
+func (ea *entropyAnalyzer) isRandom(token string) bool {
+	if isHex(token) {
+		return shannonEntropy(token) >= ea.settings.HexThreshold
+	}
+	return shannonEntropy(token) >= ea.settings.Base64Threshold
+}
+
+// Helper function to check whether the string only contains
+// hexadecimal digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to compute the Shannon entropy of the string in
+// bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	freq := make(map[rune]int)
+	for _, c := range s {
+		freq[c]++
+	}
+
+	entropy := 0.0
+	n := float64(len(s))
+	for _, count := range freq {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
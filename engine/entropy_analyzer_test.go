@@ -0,0 +1,92 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// Helper function to count the findings produced by the given rule.
+func countRule(findings []*models.FindingsInfo, id string) int {
+	n := 0
+	for _, fi := range findings {
+		if fi.RuleId == id {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFindSecretsHighEntropyStrings(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"random.go":   "var apiThing = \"q8Z3vL1xR7tN0pK5mB2wY9cF4hJ6\"\n",
+		"hex.py":      "digest = 9f86d081884c7d659a2feaa0c55ad015\n",
+		"assign.env":  "SESSION=Xk29fLpQ0zRt7wBn3Yc8Hv5Js1Dm4Ga6\n",
+		"low.go":      "var s = \"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"\n",
+		"words.md":    "the quick brown fox jumps over the lazy dog and keeps running\n",
+		"short.go":    "var id = \"a1B2c3D4\"\n",
+		"bare.txt":    "q8Z3vL1xR7tN0pK5mB2wY9cF4hJ6\n",
+		"path.go":     "var p = \"/usr/local/share/reposcanner/rules\"\n",
+		"keyword.env": "private_key=q8Z3vL1xR7tN0pK5mB2wY9cF4hJ6\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	expected := map[string]bool{
+		"/random.go":   true,
+		"/hex.py":      true,
+		"/assign.env":  true,
+		"/keyword.env": true,
+	}
+
+	found := make(map[string]bool)
+	for _, fi := range findings {
+		if fi.RuleId != "G016" {
+			continue
+		}
+		found[fi.Location.Path] = true
+		if fi.Metadata.Severity != "LOW" {
+			t.Errorf("Expected severity LOW. Got %v\n", fi.Metadata.Severity)
+		}
+	}
+
+	for path := range expected {
+		if !found[path] {
+			t.Errorf("Expected high entropy finding in %v.\n", path)
+		}
+	}
+	for path := range found {
+		if !expected[path] {
+			t.Errorf("Unexpected high entropy finding in %v.\n", path)
+		}
+	}
+
+	if countRule(findings, "G001") != 1 {
+		t.Errorf("Expected keyword finding to be reported separately.\n")
+	}
+}
+
+func TestFindSecretsEntropyThresholdsAreConfigurable(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"random.go": "var apiThing = \"q8Z3vL1xR7tN0pK5mB2wY9cF4hJ6\"\n",
+		"hex.py":    "digest = 9f86d081884c7d659a2feaa0c55ad015\n",
+	})
+
+	cfg, err := engine.ParseConfig([]byte("entropy:\n  base64Threshold: 5.5\n  hexThreshold: 3.9\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	if n := countRule(sf.FindSecrets(dir), "G016"); n != 0 {
+		t.Errorf("Expected no findings above raised thresholds. Got %v\n", n)
+	}
+
+	if _, err := engine.ParseConfig([]byte("entropy:\n  hexThreshold: 7\n")); err == nil {
+		t.Errorf("Expected invalid threshold to be rejected.\n")
+	}
+}
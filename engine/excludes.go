@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Name of the file at the root of a repository listing the paths
+// that must not be scanned, using the gitignore syntax.
+const IgnoreFileName = ".reposcannerignore"
+
+// Helper function to prepare the matcher for the paths excluded from
+// the scan of the repository at the given path. Patterns are applied
+// in order of increasing priority: the server defaults, then the
+// ignore file of the repository, then the patterns of the repository
+// record.
+func (a *SecretFinder) loadExcludes(repoPath string) {
+	patterns := make([]gitignore.Pattern, 0)
+	for _, p := range a.config.Excludes {
+		patterns = appendPattern(patterns, p)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(repoPath, IgnoreFileName)); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			patterns = appendPattern(patterns, scanner.Text())
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("Cannot read %v: %v", IgnoreFileName, err.Error())
+	}
+
+	for _, p := range a.excludes {
+		patterns = appendPattern(patterns, p)
+	}
+
+	a.excluder = gitignore.NewMatcher(patterns)
+}
+
+// Helper function to parse a line of gitignore syntax, skipping blank
+// lines and comments.
+func appendPattern(patterns []gitignore.Pattern, line string) []gitignore.Pattern {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return patterns
+	}
+
+	return append(patterns, gitignore.ParsePattern(line, nil))
+}
+
+// Helper function to check whether the given path, relative to the
+// repository root and starting with a slash, is excluded from the scan.
+func (a *SecretFinder) isExcluded(relpath string, isDir bool) bool {
+	if a.excluder == nil {
+		return false
+	}
+
+	parts := strings.Split(strings.Trim(filepath.ToSlash(relpath), "/"), "/")
+	return a.excluder.Match(parts, isDir)
+}
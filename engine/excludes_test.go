@@ -0,0 +1,61 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+)
+
+func TestFindSecretsHonoursExcludes(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"src/app.go":                 "private_key=abc\n",
+		"src/vendor/lib.go":          "private_key=abc\n",
+		"node_modules/pkg/index.js":  "private_key=abc\n",
+		"static/app.min.js":          "private_key=abc\n",
+		"fixtures/keys.txt":          "private_key=abc\n",
+		"build/out.txt":              "private_key=abc\n",
+		"docs/example.md":            "private_key=abc\n",
+		engine.IgnoreFileName:        "# test fixtures\nfixtures/\n\n*.md\n!docs/example.md\n",
+		"testdata/keep/README.md.go": "private_key=abc\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	sf.SetExcludes([]string{"build/"})
+	findings := sf.FindSecrets(dir)
+
+	expected := map[string]bool{
+		"/src/app.go":                 true,
+		"/docs/example.md":            true,
+		"/testdata/keep/README.md.go": true,
+	}
+
+	if len(findings) != len(expected) {
+		t.Errorf("Expected %v findings. Got %v\n", len(expected), len(findings))
+	}
+	for _, fi := range findings {
+		if !expected[fi.Location.Path] {
+			t.Errorf("Unexpected finding in excluded file %v\n", fi.Location.Path)
+		}
+	}
+}
+
+func TestFindSecretsInHistoryHonoursExcludes(t *testing.T) {
+	dir, _ := makeGitRepo(t, []map[string]string{
+		{"vendor/lib.go": "private_key=abc\n"},
+		{"app.go": "private_key=abc\n"},
+		{"fixtures/key.txt": "private_key=abc\n"},
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	sf.SetExcludes([]string{"fixtures/"})
+	findings, err := sf.FindSecretsInHistory(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to scan history: %v\n", err.Error())
+	}
+
+	if len(findings) != 1 || findings[0].Location.Path != "/app.go" {
+		t.Errorf("Expected a single finding in /app.go. Got %v\n", findings)
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// A match that was discarded by the false positive filter, along with
+// the reason it was discarded.
+type DiscardedFinding struct {
+	Finding *models.FindingsInfo
+	Reason  string
+}
+
+// Helper function to validate the filter settings and prepare the
+// placeholders and reference patterns for matching.
+func (f *FilterSettings) compile() error {
+	if f.MinEntropy < 0 || f.MinEntropy > 6 {
+		return fmt.Errorf("minEntropy must be between 0 and 6")
+	}
+
+	f.placeholders = make([]string, 0, len(f.Placeholders))
+	for _, p := range f.Placeholders {
+		if p != "" {
+			f.placeholders = append(f.placeholders, strings.ToLower(p))
+		}
+	}
+
+	f.references = make([]*regexp.Regexp, 0, len(f.References))
+	for _, pattern := range f.References {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid reference pattern '%v'", pattern)
+		}
+		f.references = append(f.references, re)
+	}
+
+	return nil
+}
+
+// Helper function to return the reason the match should be discarded
+// as an obvious false positive, or an empty string if it should be
+// reported. Private key blocks are recognized by their structure and
+// are never discarded. Placeholders are not looked for in the tokens of
+// rules with a HIGH confidence, whose vendor format can hold words such
+// as 'sample' by chance.
+func (f *FilterSettings) discardReason(m *match) string {
+	if !f.IsEnabled() || m.rule.Analyzer == analyzerPrivateKey || m.rule.Analyzer == analyzerEncryptedPrivateKey {
+		return ""
+	}
+
+	value := normalizeSecret(m.secret)
+	if m.rule.Confidence != "HIGH" {
+		lower := strings.ToLower(value)
+		for _, p := range f.placeholders {
+			if strings.Contains(lower, p) {
+				return fmt.Sprintf("placeholder '%v'", p)
+			}
+		}
+	}
+
+	// rules may stop the secret at the first special character, as in
+	// '${KEY}', so references are matched up to the end of the line
+	rest := value
+	if i := strings.Index(m.context, m.secret); i >= 0 {
+		rest = strings.TrimLeft(m.context[i:], "\"'`")
+	}
+	for _, re := range f.references {
+		if re.MatchString(rest) || re.MatchString(value) {
+			return fmt.Sprintf("reference '%v'", re.String())
+		}
+	}
+
+	if distinctChars(value) <= 2 {
+		return "repeated characters"
+	}
+
+	if e := shannonEntropy(value); e < f.MinEntropy {
+		return fmt.Sprintf("low entropy (%.2f bits per character)", e)
+	}
+
+	return ""
+}
+
+// Helper function to count the distinct characters of a string.
+func distinctChars(s string) int {
+	seen := make(map[rune]bool)
+	for _, r := range s {
+		seen[r] = true
+	}
+	return len(seen)
+}
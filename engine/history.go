@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// FindSecretsInHistory scans the lines added by every commit of the
+// repository at the given path, within the given range. Each finding
+// is annotated with the commit that added the offending line. Files
+// are skipped within the same limits as in the working tree, and each
+// skipped path is only reported once. Returns nil and an error if the
+// repository or the range cannot be resolved.
+func (a *SecretFinder) FindSecretsInHistory(repoPath string, hr *models.HistoryRange) ([]*models.FindingsInfo, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repository: %v", err.Error())
+	}
+
+	if hr == nil {
+		hr = &models.HistoryRange{}
+	}
+
+	commits, err := listCommits(repo, hr)
+	if err != nil {
+		return nil, err
+	}
+
+	a.loadExcludes(repoPath)
+	a.basepath = ""
+	a.collect(func() {
+		skipped := make(map[string]bool)
+		for _, c := range commits {
+			if err := a.scanCommit(c, skipped); err != nil {
+				log.Printf("Error scanning commit %v: %v", c.Hash.String(), err.Error())
+			}
+		}
+	})
+	a.verifyFindings()
+
+	return a.findings, nil
+}
+
+// Helper function to list the commits reachable from the end of the
+// range, newest first, excluding those reachable from its start.
+func listCommits(repo *git.Repository, hr *models.HistoryRange) ([]*object.Commit, error) {
+	to := hr.To
+	if to == "" {
+		to = "HEAD"
+	}
+
+	toHash, err := repo.ResolveRevision(plumbing.Revision(to))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve revision '%v': %v", to, err.Error())
+	}
+
+	exclude := make(map[plumbing.Hash]bool)
+	if hr.From != "" {
+		fromHash, err := repo.ResolveRevision(plumbing.Revision(hr.From))
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve revision '%v': %v", hr.From, err.Error())
+		}
+
+		it, err := repo.Log(&git.LogOptions{From: *fromHash})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read history: %v", err.Error())
+		}
+		it.ForEach(func(c *object.Commit) error {
+			exclude[c.Hash] = true
+			return nil
+		})
+	}
+
+	it, err := repo.Log(&git.LogOptions{From: *toHash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read history: %v", err.Error())
+	}
+	defer it.Close()
+
+	commits := make([]*object.Commit, 0)
+	err = it.ForEach(func(c *object.Commit) error {
+		if hr.MaxCommits > 0 && len(commits) >= int(hr.MaxCommits) {
+			return storer.ErrStop
+		}
+		if !exclude[c.Hash] {
+			commits = append(commits, c)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, fmt.Errorf("cannot read history: %v", err.Error())
+	}
+
+	return commits, nil
+}
+
+// Helper function to scan the lines added by a commit, compared to its
+// first parent. Files that are too large or binary are not diffed, and
+// are recorded in skipped once. Files that replace content which could
+// not be diffed are scanned in full.
+func (a *SecretFinder) scanCommit(c *object.Commit, skipped map[string]bool) error {
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	var parentTree *object.Tree
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return err
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return err
+	}
+
+	info := commitInfo(c)
+	for _, ch := range changes {
+		from, to, err := ch.Files()
+		if err != nil {
+			return err
+		}
+		if to == nil || a.isExcluded(ch.To.Name, false) {
+			continue
+		}
+
+		path := "/" + ch.To.Name
+		if reason, err := a.blobSkipReason(to); err != nil || reason != "" {
+			if err != nil {
+				log.Printf("Error when reading %v: %v", path, err.Error())
+				reason = skipUnreadable
+			}
+			if !skipped[path] {
+				skipped[path] = true
+				a.skip(path, reason)
+			}
+			continue
+		}
+
+		if from != nil {
+			if reason, err := a.blobSkipReason(from); err == nil && reason == "" {
+				patch, err := ch.Patch()
+				if err != nil {
+					return err
+				}
+				for _, fp := range patch.FilePatches() {
+					a.scanAddedLines(path, fp.Chunks(), info)
+				}
+				continue
+			}
+		}
+
+		if err := a.scanAddedFile(path, to, info); err != nil {
+			log.Printf("Error when scanning %v: %v", path, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Helper function to return the reason for not scanning the given
+// file of a commit, or an empty string if it can be scanned. Only the
+// start of the file is read to tell whether it is binary.
+func (a *SecretFinder) blobSkipReason(f *object.File) (string, error) {
+	if max := a.config.Limits.MaxFileSize; max > 0 && f.Size > max {
+		return skipTooLarge, nil
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	if isBinary(head[:n]) {
+		return skipBinary, nil
+	}
+	return "", nil
+}
+
+// Helper function to run the analyzers over every line of a file of a
+// commit, as if all of them were added by the commit.
+func (a *SecretFinder) scanAddedFile(path string, f *object.File, info *models.CommitInfo) error {
+	fs := a.newFileScan(path)
+	if len(fs.analyzers) == 0 {
+		return nil
+	}
+	fs.commit = info
+
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	err = readLines(bufio.NewReader(r), fs.scanLine)
+	fs.finish()
+
+	return err
+}
+
+// Helper function to run the analyzers over each run of added lines
+// of a file patch, using the line numbers of the new file.
+func (a *SecretFinder) scanAddedLines(path string, chunks []fdiff.Chunk, info *models.CommitInfo) {
+	lineCnt := 0
+	var offset int64
+	for _, chunk := range chunks {
+		lines := strings.SplitAfter(chunk.Content(), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+
+		switch chunk.Type() {
+		case fdiff.Equal:
+			lineCnt += len(lines)
+			offset += int64(len(chunk.Content()))
+		case fdiff.Add:
+			fs := a.newFileScan(path)
+			fs.commit = info
+			for _, line := range lines {
+				lineCnt++
+				fs.scanLine(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), lineCnt, offset)
+				offset += int64(len(line))
+			}
+			fs.finish()
+		}
+	}
+}
+
+// Helper function to convert the author details of a commit.
+func commitInfo(c *object.Commit) *models.CommitInfo {
+	return &models.CommitInfo{
+		Sha:       c.Hash.String(),
+		Author:    c.Author.Name,
+		Email:     c.Author.Email,
+		Timestamp: c.Author.When.Format(time.RFC3339),
+	}
+}
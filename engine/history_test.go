@@ -0,0 +1,222 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Helper function to create a git repository in a temporary directory
+// with one commit per entry of the list. Each entry maps file paths to
+// their new contents, an empty string deletes the file. Returns the
+// repository path and the commit hashes in order.
+func makeGitRepo(t *testing.T, commits []map[string]string) (string, []string) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Could not initialize repository: %v\n", err.Error())
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Could not open worktree: %v\n", err.Error())
+	}
+
+	hashes := make([]string, 0, len(commits))
+	when := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, files := range commits {
+		for name, contents := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if contents == "" {
+				if _, err := wt.Remove(name); err != nil {
+					t.Fatalf("Could not remove %v: %v\n", name, err.Error())
+				}
+				continue
+			}
+
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatalf("Could not write %v: %v\n", name, err.Error())
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatalf("Could not add %v: %v\n", name, err.Error())
+			}
+		}
+
+		hash, err := wt.Commit("commit", &git.CommitOptions{
+			Author: &object.Signature{
+				Name:  "Dev Eloper",
+				Email: "dev@example.com",
+				When:  when.Add(time.Duration(i) * time.Hour),
+			},
+		})
+		if err != nil {
+			t.Fatalf("Could not commit: %v\n", err.Error())
+		}
+		hashes = append(hashes, hash.String())
+	}
+
+	return dir, hashes
+}
+
+func TestFindSecretsInHistoryFindsDeletedSecret(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"README.md": "hello\n"},
+		{"config/app.env": "name=app\nprivate_key=abcdef\n"},
+		{"config/app.env": "name=app\n"},
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings, err := sf.FindSecretsInHistory(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to scan history: %v\n", err.Error())
+	}
+
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding. Got %v\n", len(findings))
+	}
+
+	fi := findings[0]
+	if fi.RuleId != "G001" || fi.Location.Path != "/config/app.env" || fi.Location.Positions.Begin.Line != 2 {
+		t.Errorf("Unexpected finding %v at %v:%v\n", fi.RuleId, fi.Location.Path, fi.Location.Positions.Begin.Line)
+	}
+
+	if fi.Commit == nil {
+		t.Fatalf("Expected finding to carry commit details.\n")
+	}
+	if fi.Commit.Sha != hashes[1] {
+		t.Errorf("Expected commit %v. Got %v\n", hashes[1], fi.Commit.Sha)
+	}
+	if fi.Commit.Author != "Dev Eloper" || fi.Commit.Email != "dev@example.com" {
+		t.Errorf("Unexpected author %v <%v>\n", fi.Commit.Author, fi.Commit.Email)
+	}
+	if fi.Commit.Timestamp != "2022-06-01T13:00:00Z" {
+		t.Errorf("Unexpected commit timestamp %v\n", fi.Commit.Timestamp)
+	}
+
+	// the working tree no longer contains the secret
+	sf.Initialize()
+	if n := len(sf.FindSecrets(dir)); n != 0 {
+		t.Errorf("Expected no findings in the working tree. Got %v\n", n)
+	}
+}
+
+func TestFindSecretsInHistoryUsesAddedLineNumbers(t *testing.T) {
+	dir, _ := makeGitRepo(t, []map[string]string{
+		{"a.txt": "one\ntwo\nthree\n"},
+		{"a.txt": "one\ntwo\npublic_key=xyz\nthree\nprivate_key=abc\n"},
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings, err := sf.FindSecretsInHistory(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to scan history: %v\n", err.Error())
+	}
+
+	lines := make(map[string]int32)
+	for _, fi := range findings {
+		lines[fi.RuleId] = fi.Location.Positions.Begin.Line
+	}
+
+	if lines["G002"] != 3 || lines["G001"] != 5 {
+		t.Errorf("Expected findings on lines 3 and 5. Got %v\n", lines)
+	}
+}
+
+func TestFindSecretsInHistoryRange(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.txt": "private_key=one\n"},
+		{"b.txt": "private_key=two\n"},
+		{"c.txt": "private_key=three\n"},
+		{"d.txt": "private_key=four\n"},
+	})
+
+	ranges := []struct {
+		hr       *models.HistoryRange
+		expected []string
+	}{
+		{&models.HistoryRange{From: hashes[1]}, []string{"/d.txt", "/c.txt"}},
+		{&models.HistoryRange{To: hashes[2]}, []string{"/c.txt", "/b.txt", "/a.txt"}},
+		{&models.HistoryRange{From: hashes[0], To: hashes[2]}, []string{"/c.txt", "/b.txt"}},
+		{&models.HistoryRange{MaxCommits: 1}, []string{"/d.txt"}},
+	}
+
+	for i, r := range ranges {
+		var sf engine.SecretFinder
+		sf.Initialize()
+		findings, err := sf.FindSecretsInHistory(dir, r.hr)
+		if err != nil {
+			t.Fatalf("Failed to scan history: %v\n", err.Error())
+		}
+
+		if len(findings) != len(r.expected) {
+			t.Errorf("Range %v: expected %v findings. Got %v\n", i, len(r.expected), len(findings))
+			continue
+		}
+		for k, fi := range findings {
+			if fi.Location.Path != r.expected[k] {
+				t.Errorf("Range %v: expected finding in %v. Got %v\n", i, r.expected[k], fi.Location.Path)
+			}
+		}
+	}
+}
+
+func TestFindSecretsInHistoryInvalidRange(t *testing.T) {
+	dir, _ := makeGitRepo(t, []map[string]string{{"a.txt": "hello\n"}})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	if _, err := sf.FindSecretsInHistory(dir, &models.HistoryRange{From: "not-a-revision"}); err == nil {
+		t.Errorf("Expected failure for unknown revision.\n")
+	}
+
+	if _, err := sf.FindSecretsInHistory(t.TempDir(), nil); err == nil {
+		t.Errorf("Expected failure for a directory that is not a repository.\n")
+	}
+}
+
+func TestFindSecretsInHistoryAppliesLimits(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte("limits:\n  maxFileSize: 100\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	large := strings.Repeat("#\n", 50)
+	dir, _ := makeGitRepo(t, []map[string]string{
+		{"small.txt": "private_key=abc\n", "large.txt": large + "private_key=abc\n", "blob.bin": "private_key=abc\n\x00\n"},
+		{"large.txt": large + "private_key=def\n", "blob.bin": "private_key=def\n\x00\n"},
+		// a file that shrinks below the limit is scanned in full
+		{"large.txt": "name=app\nprivate_key=ghi\n"},
+	})
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings, err := sf.FindSecretsInHistory(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to scan history: %v\n", err.Error())
+	}
+
+	if len(findings) != 2 || findingAt(findings, "/small.txt") == nil || findingAt(findings, "/large.txt") == nil {
+		t.Fatalf("Expected findings in /small.txt and in the last version of /large.txt. Got %v\n", len(findings))
+	}
+	if line := findingAt(findings, "/large.txt").Location.Positions.Begin.Line; line != 2 {
+		t.Errorf("Expected the finding of /large.txt on line 2. Got %v\n", line)
+	}
+
+	skipped := make(map[string]string)
+	for _, s := range sf.Skipped() {
+		skipped[s.Path] = s.Reason
+	}
+	if len(sf.Skipped()) != 2 || skipped["/large.txt"] != "too large" || skipped["/blob.bin"] != "binary" {
+		t.Errorf("Expected each of the skipped files to be reported once. Got %v\n", sf.Skipped())
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Verifier asking an HTTP API whether a token is live. The request is
+// prepared by the service specific function, and the status of the
+// response tells whether the token was accepted.
+type httpVerifier struct {
+	client  *http.Client
+	request func(ctx context.Context, secret string) (*http.Request, error)
+	// statuses of the response codes that do not mean the token was
+	// rejected, such as tokens lacking a permission
+	accepted map[int]string
+}
+
+func (hv *httpVerifier) Verify(ctx context.Context, secret string) (string, error) {
+	req, err := hv.request(ctx, secret)
+	if err != nil {
+		return VerificationError, err
+	}
+
+	resp, err := hv.client.Do(req)
+	if err != nil {
+		return VerificationError, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if status, ok := hv.accepted[resp.StatusCode]; ok {
+		return status, nil
+	}
+	return responseStatus(resp.StatusCode), nil
+}
+
+// Helper function to return the verification status matching the
+// response code of an API. Other responses, such as rate limiting or
+// server errors, tell nothing about the token.
+func responseStatus(code int) string {
+	switch code {
+	case http.StatusOK:
+		return VerificationVerified
+	case http.StatusUnauthorized:
+		return VerificationInvalid
+	default:
+		return VerificationUnknown
+	}
+}
+
+// Verifier for GitHub tokens, using the authenticated user endpoint.
+func newGithubVerifier(baseUrl string, client *http.Client) Verifier {
+	return &httpVerifier{
+		client: client,
+		request: func(ctx context.Context, secret string) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/user", nil)
+			if err == nil {
+				req.Header.Set("Authorization", "token "+secret)
+				req.Header.Set("Accept", "application/vnd.github+json")
+			}
+			return req, err
+		},
+	}
+}
+
+// Verifier for GitLab personal access tokens, using the authenticated
+// user endpoint.
+func newGitlabVerifier(baseUrl string, client *http.Client) Verifier {
+	return &httpVerifier{
+		client: client,
+		request: func(ctx context.Context, secret string) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/user", nil)
+			if err == nil {
+				req.Header.Set("PRIVATE-TOKEN", secret)
+			}
+			return req, err
+		},
+	}
+}
+
+// Verifier for Stripe keys, using the balance endpoint. Restricted keys
+// without access to the balance are still live.
+func newStripeVerifier(baseUrl string, client *http.Client) Verifier {
+	return &httpVerifier{
+		client: client,
+		request: func(ctx context.Context, secret string) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/v1/balance", nil)
+			if err == nil {
+				req.SetBasicAuth(secret, "")
+			}
+			return req, err
+		},
+		accepted: map[int]string{http.StatusForbidden: VerificationVerified},
+	}
+}
+
+// Verifier for Slack tokens, using the auth.test method. Slack answers
+// every request with a 200 response telling whether the call succeeded.
+type slackVerifier struct {
+	baseUrl string
+	client  *http.Client
+}
+
+func newSlackVerifier(baseUrl string, client *http.Client) Verifier {
+	return &slackVerifier{baseUrl: baseUrl, client: client}
+}
+
+func (sv *slackVerifier) Verify(ctx context.Context, secret string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sv.baseUrl+"/auth.test", nil)
+	if err != nil {
+		return VerificationError, err
+	}
+	req.Header.Set("Authorization", "Bearer "+secret)
+
+	resp, err := sv.client.Do(req)
+	if err != nil {
+		return VerificationError, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseStatus(resp.StatusCode), nil
+	}
+
+	var body struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err != nil {
+		return VerificationError, err
+	}
+
+	switch {
+	case body.Ok:
+		return VerificationVerified, nil
+	case body.Error == "invalid_auth" || body.Error == "not_authed" || body.Error == "token_revoked" ||
+		body.Error == "token_expired" || body.Error == "account_inactive":
+		return VerificationInvalid, nil
+	default:
+		return VerificationUnknown, nil
+	}
+}
@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v3"
+)
+
+// Baseline holds the results of a previous successful scan of the
+// checked-out tree, used as the starting point of an incremental scan.
+type Baseline struct {
+	// the commit that was scanned
+	Commit string
+
+	// the findings that were reported for that commit
+	Findings []*models.FindingsInfo
+
+	// the files that were not scanned
+	Skipped []*models.SkippedFile
+
+	// the hash of the rules and excludes that were applied, as returned
+	// by ConfigHash
+	ConfigHash string
+}
+
+// FindSecretsSince scans the files of the repository at the given path
+// that changed between the baseline commit and HEAD. Findings of the
+// baseline in files that did not change are carried forward, as well
+// as the files it skipped, so the result covers the whole tree. Falls
+// back to a full scan if there is no baseline, its commit is not part
+// of the repository, or its rules and excludes differ. Returns nil and
+// an error if the repository cannot be read.
+func (a *SecretFinder) FindSecretsSince(repoPath string, base *Baseline) ([]*models.FindingsInfo, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repository: %v", err.Error())
+	}
+
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if base == nil || base.Commit == "" {
+		log.Println("No baseline available, scanning the full tree.")
+		return a.FindSecrets(repoPath), nil
+	}
+
+	// other rules or excludes could report other files and findings
+	if base.ConfigHash != a.ConfigHash(repoPath) {
+		log.Println("Rules or excludes changed since the baseline, scanning the full tree.")
+		return a.FindSecrets(repoPath), nil
+	}
+
+	baseCommit, err := repo.CommitObject(plumbing.NewHash(base.Commit))
+	if err != nil {
+		log.Printf("Baseline commit %v not found, scanning the full tree.", base.Commit)
+		return a.FindSecrets(repoPath), nil
+	}
+
+	changed, err := changedPaths(baseCommit, head)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare with baseline commit: %v", err.Error())
+	}
+
+	a.loadExcludes(repoPath)
+	paths := make([]string, 0, len(changed))
+	for path, exists := range changed {
+		if exists && !a.isExcluded(path, false) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	a.basepath = repoPath
+	a.collect(func() {
+		a.scanFiles(func() {
+			for _, path := range paths {
+				full := filepath.Join(repoPath, filepath.FromSlash(path[1:]))
+
+				// files left out of a sparse checkout are not scanned, and
+				// submodules that moved to another commit are scanned whole
+				info, err := os.Stat(full)
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err == nil && info.IsDir() {
+					if err := filepath.WalkDir(full, a.WalkDirHandler); err != nil {
+						log.Printf("Error traversing submodule %v: %s", path, err.Error())
+					}
+					continue
+				}
+				a.queue <- full
+			}
+		})
+	})
+	a.verifyFindings()
+
+	for _, fi := range base.Findings {
+		if fi.Location == nil {
+			continue
+		}
+		if !a.isChanged(changed, fi.Location.Path) && !a.isExcluded(fi.Location.Path, false) {
+			a.findings = append(a.findings, fi)
+		}
+	}
+
+	for _, sf := range base.Skipped {
+		if !a.isChanged(changed, sf.Path) && !a.isExcluded(sf.Path, false) {
+			a.skipped = append(a.skipped, sf)
+		}
+	}
+	a.sortResults()
+
+	return a.findings, nil
+}
+
+// Helper function to check whether the file at the given path changed
+// since the baseline, either itself or through the submodule holding
+// it moving to another commit. Entries of an archive change along with
+// the archive.
+func (a *SecretFinder) isChanged(changed map[string]bool, relpath string) bool {
+	if i := strings.Index(relpath, archiveSeparator); i >= 0 {
+		relpath = relpath[:i]
+	}
+
+	if _, ok := changed[relpath]; ok {
+		return true
+	}
+
+	for sm := a.submoduleOf(relpath); sm != nil; sm = a.submoduleOf(sm.Path) {
+		if _, ok := changed[sm.Path]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ConfigHash returns a hash of the rules, settings and excludes that
+// the secret finder applies to the repository at the given path. The
+// results of a scan can only be carried forward by a scan with the same
+// hash.
+func (a *SecretFinder) ConfigHash(repoPath string) string {
+	h := sha256.New()
+	if data, err := yaml.Marshal(a.config); err == nil {
+		h.Write(data)
+	}
+	h.Write([]byte{0})
+	for _, p := range a.excludes {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	if data, err := os.ReadFile(filepath.Join(repoPath, IgnoreFileName)); err == nil {
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HeadCommit returns the hash of the commit checked out in the
+// repository at the given path.
+func HeadCommit(repoPath string) (string, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot open repository: %v", err.Error())
+	}
+
+	c, err := headCommit(repo)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Hash.String(), nil
+}
+
+// Helper function to retrieve the commit checked out in the repository.
+func headCommit(repo *git.Repository) (*object.Commit, error) {
+	ref, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve HEAD: %v", err.Error())
+	}
+
+	c, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("cannot read HEAD commit: %v", err.Error())
+	}
+
+	return c, nil
+}
+
+// Helper function to list the paths of the files that differ between
+// two commits, in the same form as the paths of the findings. Each path
+// maps to whether the file exists in the newer commit.
+func changedPaths(from, to *object.Commit) (map[string]bool, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for _, ch := range changes {
+		if ch.From.Name != "" {
+			changed["/"+ch.From.Name] = false
+		}
+		if ch.To.Name != "" {
+			changed["/"+ch.To.Name] = true
+		}
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,184 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// Helper function to create a finding reported on the first line of
+// the given file.
+func makeFinding(ruleId, path string) *models.FindingsInfo {
+	return &models.FindingsInfo{
+		Type_:  "sast",
+		RuleId: ruleId,
+		Location: &models.FindingsLocation{
+			Path: path,
+			Positions: &models.FileLocation{
+				Begin: &models.LineLocation{Line: 1},
+			},
+		},
+	}
+}
+
+func TestFindSecretsSinceScansChangedFiles(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{
+			"a.txt": "private_key=one\n",
+			"b.txt": "private_key=two\n",
+			"c.txt": "hello\n",
+		},
+		{
+			"b.txt": "",
+			"c.txt": "hello\npublic_key=three\n",
+		},
+	})
+
+	carried := makeFinding("G001", "/a.txt")
+	base := &engine.Baseline{
+		Commit: hashes[0],
+		Findings: []*models.FindingsInfo{
+			carried,
+			makeFinding("G001", "/b.txt"),
+		},
+	}
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	base.ConfigHash = sf.ConfigHash(dir)
+	findings, err := sf.FindSecretsSince(dir, base)
+	if err != nil {
+		t.Fatalf("Failed to scan changes: %v\n", err.Error())
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings. Got %v\n", len(findings))
+	}
+
+	// carried forward findings are sorted along with the new ones
+	if findings[0] != carried {
+		t.Errorf("Expected the finding of the unchanged file to be carried forward.\n")
+	}
+
+	if fi := findings[1]; fi.RuleId != "G002" || fi.Location.Path != "/c.txt" || fi.Location.Positions.Begin.Line != 2 {
+		t.Errorf("Unexpected finding %v at %v:%v\n", fi.RuleId, fi.Location.Path, fi.Location.Positions.Begin.Line)
+	}
+}
+
+func TestFindSecretsSinceRescansChangedArchives(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"bundle.zip": makeZip(t, map[string]string{"config.env": "private_key=one\n"})},
+		{"bundle.zip": makeZip(t, map[string]string{"config.env": "# settings\nprivate_key=one\n"})},
+	})
+
+	base := &engine.Baseline{
+		Commit:   hashes[0],
+		Findings: []*models.FindingsInfo{makeFinding("G001", "/bundle.zip!/config.env")},
+		Skipped:  []*models.SkippedFile{{Path: "/bundle.zip!/app.bin", Reason: "binary"}},
+	}
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	base.ConfigHash = sf.ConfigHash(dir)
+	findings, err := sf.FindSecretsSince(dir, base)
+	if err != nil {
+		t.Fatalf("Failed to scan changes: %v\n", err.Error())
+	}
+
+	if len(findings) != 1 {
+		t.Fatalf("Expected the archive to be rescanned without the stale finding. Got %v findings\n", len(findings))
+	}
+	if fi := findings[0]; fi.Location.Path != "/bundle.zip!/config.env" || fi.Location.Positions.Begin.Line != 2 {
+		t.Errorf("Unexpected finding at %v:%v\n", fi.Location.Path, fi.Location.Positions.Begin.Line)
+	}
+
+	if len(sf.Skipped()) != 0 {
+		t.Errorf("Expected the skipped entries of the archive not to be carried forward. Got %v\n", sf.Skipped())
+	}
+}
+
+func TestFindSecretsSinceRescansWhenExcludesChange(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{
+			"app.env":        "private_key=one\n",
+			"deploy/lib.env": "private_key=two\n",
+		},
+		{
+			"README.md": "# App\n",
+		},
+	})
+
+	// the baseline left the deployment files out
+	var before engine.SecretFinder
+	before.Initialize()
+	before.SetExcludes([]string{"deploy/"})
+	base := &engine.Baseline{
+		Commit:     hashes[0],
+		Findings:   []*models.FindingsInfo{makeFinding("G001", "/app.env")},
+		ConfigHash: before.ConfigHash(dir),
+	}
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	if sf.ConfigHash(dir) == base.ConfigHash {
+		t.Fatalf("Expected the hash to depend on the excludes.\n")
+	}
+
+	findings, err := sf.FindSecretsSince(dir, base)
+	if err != nil {
+		t.Fatalf("Failed to scan changes: %v\n", err.Error())
+	}
+
+	if len(findings) != 2 || findingAt(findings, "/deploy/lib.env") == nil {
+		t.Errorf("Expected the whole tree to be scanned again. Got %v findings\n", len(findings))
+	}
+	if findingAt(findings, "/app.env") == base.Findings[0] {
+		t.Errorf("Expected the finding of the baseline to be replaced.\n")
+	}
+}
+
+func TestFindSecretsSinceFallsBackToFullScan(t *testing.T) {
+	dir, _ := makeGitRepo(t, []map[string]string{
+		{"a.txt": "private_key=one\n"},
+		{"b.txt": "public_key=two\n"},
+	})
+
+	bases := []*engine.Baseline{
+		nil,
+		{Commit: "0123456789abcdef0123456789abcdef01234567"},
+	}
+
+	for i, base := range bases {
+		var sf engine.SecretFinder
+		sf.Initialize()
+		findings, err := sf.FindSecretsSince(dir, base)
+		if err != nil {
+			t.Fatalf("Baseline %v: failed to scan changes: %v\n", i, err.Error())
+		}
+
+		if len(findings) != 2 {
+			t.Errorf("Baseline %v: expected 2 findings. Got %v\n", i, len(findings))
+		}
+	}
+}
+
+func TestHeadCommit(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.txt": "one\n"},
+		{"a.txt": "two\n"},
+	})
+
+	sha, err := engine.HeadCommit(dir)
+	if err != nil {
+		t.Fatalf("Failed to read head commit: %v\n", err.Error())
+	}
+
+	if sha != hashes[1] {
+		t.Errorf("Expected head commit %v. Got %v\n", hashes[1], sha)
+	}
+
+	if _, err := engine.HeadCommit(t.TempDir()); err == nil {
+		t.Errorf("Expected failure for a directory that is not a repository.\n")
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Number of bytes inspected to decide whether a file is binary.
+	sniffLength = 8000
+	// Shortest line length limit that can be configured.
+	minLineLength = 80
+	// Long lines are preferably split at a separator found within this
+	// many bytes of the limit, so that tokens are kept whole.
+	maxSplitWindow = 256
+)
+
+// Reasons for not scanning a file.
+const (
+	skipBinary     = "binary"
+	skipTooLarge   = "too large"
+	skipTooDeep    = "too deep"
+	skipUnreadable = "unreadable"
+)
+
+var errBinaryContent = errors.New("binary content")
+
+// Helper function to check whether the start of a file looks like
+// binary content, because it contains a NUL byte or is not valid UTF-8.
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+
+	// the start of the file can end in the middle of a character
+	for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+		if utf8.RuneStart(head[len(head)-i]) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				head = head[:len(head)-i]
+			}
+			break
+		}
+	}
+
+	return !utf8.Valid(head)
+}
+
+// Helper function to read the lines of r, without their line endings,
+// passing each of them to fn along with its number and the offset of
+// its first byte. Unlike bufio.Scanner there is no limit on the length
+// of a line.
+func readLines(r *bufio.Reader, fn func(line string, num int, offset int64)) error {
+	var offset int64
+	for num := 1; ; num++ {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			fn(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), num, offset)
+			offset += int64(len(line))
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Helper function to split a line into chunks of at most max bytes.
+// Each chunk ends at the last whitespace or punctuation close to the
+// limit if there is one.
+func splitLongLine(line string, max int) []string {
+	window := max / 4
+	if window > maxSplitWindow {
+		window = maxSplitWindow
+	}
+
+	chunks := make([]string, 0, len(line)/max+1)
+	for len(line) > max {
+		cut := max
+		if i := strings.LastIndexAny(line[max-window:max], " \t,;"); i >= 0 {
+			cut = max - window + i + 1
+		}
+		chunks = append(chunks, line[:cut])
+		line = line[cut:]
+	}
+
+	return append(chunks, line)
+}
@@ -0,0 +1,103 @@
+package engine_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+)
+
+func TestFindSecretsSkipsBinaryFiles(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"app.go":    "private_key=abc\n",
+		"blob.bin":  "private_key=abc\n\x00\x01\x02\n",
+		"image.png": "\x89PNG\r\n\x1a\nprivate_key=abc\n",
+		// text that looks like another kind of file is still scanned
+		"doc.ps":       "%!PS-Adobe-3.0\n% private_key=abc\n",
+		"app.json":     "{\"private_key\": \"abc\"}\n",
+		"caf\u00e9.md": "# Caf\u00e9 \u2615\nprivate_key=abc\n",
+		// the inspected start of the file ends in the middle of a character
+		"long.md": strings.Repeat("a", 7999) + "\u00e9\nprivate_key=abc\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	scanned := make(map[string]bool)
+	for _, fi := range findings {
+		scanned[fi.Location.Path] = true
+	}
+	if len(scanned) != 5 || !scanned["/app.go"] || !scanned["/doc.ps"] || !scanned["/app.json"] || !scanned["/caf\u00e9.md"] || !scanned["/long.md"] {
+		t.Errorf("Expected findings in every text file. Got %v\n", scanned)
+	}
+
+	skipped := make(map[string]string)
+	for _, s := range sf.Skipped() {
+		skipped[s.Path] = s.Reason
+	}
+
+	if len(skipped) != 2 || skipped["/blob.bin"] != "binary" || skipped["/image.png"] != "binary" {
+		t.Errorf("Expected both binary files to be skipped. Got %v\n", skipped)
+	}
+}
+
+func TestFindSecretsSkipsLargeFiles(t *testing.T) {
+	cfg, err := engine.ParseConfig([]byte("limits:\n  maxFileSize: 100\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	dir := makeSourceTree(t, map[string]string{
+		"small.txt": "private_key=abc\n",
+		"large.txt": strings.Repeat("#\n", 50) + "private_key=abc\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 1 || findings[0].Location.Path != "/small.txt" {
+		t.Errorf("Expected a single finding in /small.txt. Got %v\n", findings)
+	}
+
+	skipped := sf.Skipped()
+	if len(skipped) != 1 || skipped[0].Path != "/large.txt" || skipped[0].Reason != "too large" {
+		t.Errorf("Expected /large.txt to be skipped as too large. Got %v\n", skipped)
+	}
+}
+
+func TestFindSecretsScansLongLines(t *testing.T) {
+	token := "ghp_" + strings.Repeat("aB3dE5", 6)
+
+	dir := makeSourceTree(t, map[string]string{
+		// longer than the buffer of a bufio.Scanner
+		"bundle.js": strings.Repeat("var a=1;", 20000) + "private_key=abc;\nprivate_key=def\n",
+		// token straddles the chunk limit
+		"chunked.txt": strings.Repeat("x,", 45) + token + "\n",
+	})
+
+	cfg, err := engine.ParseConfig([]byte("limits:\n  maxLineLength: 100\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	lines := make(map[string][]int32)
+	for _, fi := range findings {
+		lines[fi.Location.Path+" "+fi.RuleId] = append(lines[fi.Location.Path+" "+fi.RuleId], fi.Location.Positions.Begin.Line)
+	}
+
+	if l := lines["/bundle.js G001"]; len(l) != 2 || l[0] != 1 || l[1] != 2 {
+		t.Errorf("Expected G001 findings on lines 1 and 2 of the bundle. Got %v\n", l)
+	}
+	if l := lines["/chunked.txt G005"]; len(l) != 1 {
+		t.Errorf("Expected the token split across chunks to be found. Got %v\n", lines)
+	}
+	if len(sf.Skipped()) != 0 {
+		t.Errorf("Expected no skipped files. Got %v\n", sf.Skipped())
+	}
+}
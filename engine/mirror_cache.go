@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// MirrorCache keeps a bare mirror of each scanned repository, keyed by
+// url, so that a scan only fetches what changed since the previous
+// one. Each scan checks out its own worktree, which borrows the objects
+// of the mirror instead of copying them. Mirrors that are not in use
+// are removed, least recently used first, once the cache grows past
+// its quota.
+//
+// The remote is contacted before every checkout, with the credential
+// of the scan, so a mirror never serves a repository to a scan that
+// could not clone it.
+type MirrorCache struct {
+	dir     string
+	quota   int64
+	lock    sync.Mutex
+	mirrors map[string]*mirror
+}
+
+// A mirror in the cache. Fetches into the mirror are serialized, while
+// any number of scans can use it at the same time.
+type mirror struct {
+	path     string
+	users    int
+	lastUsed time.Time
+	size     int64
+	fetch    sync.Mutex
+}
+
+// Create a cache of mirrors in the given directory, holding at most
+// quota bytes, or with no limit if the quota is 0. Mirrors left in the
+// directory by a previous run are reused.
+func NewMirrorCache(dir string, quota int64) (*MirrorCache, error) {
+	if quota < 0 {
+		return nil, fmt.Errorf("mirror cache quota must not be negative")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create mirror cache: %v", err.Error())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mirror cache: %v", err.Error())
+	}
+
+	c := &MirrorCache{dir: dir, quota: quota, mirrors: make(map[string]*mirror)}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasSuffix(e.Name(), ".git") {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(dir, e.Name())
+		c.mirrors[strings.TrimSuffix(e.Name(), ".git")] = &mirror{
+			path:     path,
+			lastUsed: info.ModTime(),
+			size:     dirSize(path),
+		}
+	}
+
+	c.lock.Lock()
+	c.evict()
+	c.lock.Unlock()
+
+	return c, nil
+}
+
+// Checkout updates the mirror of the repository at the given url and
+// checks out the ref or commit of the options from it, in the same way
+// as CheckoutRepository. The history is always fetched in full, so the
+// depth of the options is ignored, and the size limit applies to what
+// is fetched into the mirror and to the checkout together. The
+// checkout relies on the mirror until Release is called with the same
+// url, which must be done whether the checkout succeeded or not.
+func (c *MirrorCache) Checkout(url string, opts *CheckoutOptions, checkoutDir string) (*Checkout, error) {
+	m := c.acquire(url)
+
+	if opts.SubmoduleDepth < 0 || opts.SubmoduleDepth > MaxSubmoduleDepth {
+		return nil, fmt.Errorf("submodule depth must be between 0 and %v", MaxSubmoduleDepth)
+	}
+
+	return withCloneLimits(opts, []string{m.path, checkoutDir}, func(ctx context.Context) (*Checkout, error) {
+		auth, err := authMethod(opts.Credential)
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone url %v: %v", url, err.Error())
+		}
+
+		m.fetch.Lock()
+		refName, hash, err := m.update(ctx, url, opts, auth)
+		m.fetch.Unlock()
+		c.resize(m)
+		if err != nil {
+			return nil, err
+		}
+
+		repo, err := git.PlainInit(checkoutDir, false)
+		if err == nil {
+			_, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+		}
+		if err == nil {
+			err = borrowObjects(checkoutDir, m.path)
+		}
+		if err == nil && opts.Commit == "" && refName.IsBranch() {
+			err = repo.Storer.SetReference(plumbing.NewHashReference(refName, hash))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create worktree: %v", err.Error())
+		}
+
+		return checkoutWorktree(ctx, repo, url, opts, refName, hash, auth)
+	})
+}
+
+// Release signals that a checkout of the repository at the given url
+// is no longer used, so its mirror can be removed from the cache.
+func (c *MirrorCache) Release(url string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if m, ok := c.mirrors[mirrorKey(url)]; ok && m.users > 0 {
+		m.users--
+		c.evict()
+	}
+}
+
+// Helper function to retrieve the mirror of the repository, adding it
+// to the cache if needed, and mark it as used.
+func (c *MirrorCache) acquire(url string) *mirror {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := mirrorKey(url)
+	m, ok := c.mirrors[key]
+	if !ok {
+		m = &mirror{path: filepath.Join(c.dir, key+".git")}
+		c.mirrors[key] = m
+	}
+
+	m.users++
+	m.lastUsed = time.Now()
+	return m
+}
+
+// Helper function to measure the mirror after a fetch, then remove
+// other mirrors if the cache went over its quota.
+func (c *MirrorCache) resize(m *mirror) {
+	size := dirSize(m.path)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	m.size = size
+	c.evict()
+}
+
+// Helper function to remove the least recently used mirrors that are
+// not in use until the cache fits in its quota. The cache must be
+// locked.
+func (c *MirrorCache) evict() {
+	if c.quota == 0 {
+		return
+	}
+
+	total := int64(0)
+	keys := make([]string, 0, len(c.mirrors))
+	for k, m := range c.mirrors {
+		total += m.size
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return c.mirrors[keys[i]].lastUsed.Before(c.mirrors[keys[j]].lastUsed)
+	})
+
+	for _, k := range keys {
+		if total <= c.quota {
+			return
+		}
+
+		m := c.mirrors[k]
+		if m.users > 0 {
+			continue
+		}
+
+		if err := os.RemoveAll(m.path); err != nil {
+			log.Printf("Failed to remove mirror %v: %v", m.path, err.Error())
+			continue
+		}
+		delete(c.mirrors, k)
+		total -= m.size
+	}
+}
+
+// Helper function to fetch the ref or commit of the options into the
+// mirror, creating the mirror on first use.
+func (m *mirror) update(ctx context.Context, url string, opts *CheckoutOptions, auth transport.AuthMethod) (plumbing.ReferenceName, plumbing.Hash, error) {
+	repo, err := git.PlainOpen(m.path)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInit(m.path, true)
+	}
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to open mirror of url %v: %v", url, err.Error())
+	}
+
+	return fetchRevision(ctx, repo, url, opts, auth, true)
+}
+
+// Helper function to let the repository checked out in the directory
+// read the objects of the mirror, as git does for shared clones.
+func borrowObjects(checkoutDir, mirrorPath string) error {
+	info := filepath.Join(checkoutDir, git.GitDirName, "objects", "info")
+	if err := os.MkdirAll(info, 0755); err != nil {
+		return err
+	}
+
+	objects, err := filepath.Abs(filepath.Join(mirrorPath, "objects"))
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(info, "alternates"), []byte(objects+"\n"), 0644)
+}
+
+// Helper function to derive the name of the mirror of a repository
+// from its url.
+func mirrorKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,306 @@
+package engine_test
+
+import (
+	"crypto/rand"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func appendCommit(t *testing.T, dir, name, contents string) string {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("Could not open repository: %v\n", err.Error())
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Could not open worktree: %v\n", err.Error())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatalf("Could not write %v: %v\n", name, err.Error())
+	}
+	if _, err := wt.Add(name); err != nil {
+		t.Fatalf("Could not add %v: %v\n", name, err.Error())
+	}
+
+	hash, err := wt.Commit("commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Dev Eloper", Email: "dev@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Could not commit: %v\n", err.Error())
+	}
+
+	return hash.String()
+}
+
+func cacheSize(t *testing.T, dir string) int64 {
+	size := int64(0)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+func cachedMirrors(t *testing.T, dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Could not read cache directory: %v\n", err.Error())
+	}
+	return len(entries)
+}
+
+func TestMirrorCacheResolvesRefs(t *testing.T) {
+	src, hashes := makeRefsRepo(t)
+
+	cache, err := engine.NewMirrorCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	tests := []struct {
+		opts   engine.CheckoutOptions
+		ref    string
+		commit string
+	}{
+		{engine.CheckoutOptions{Branch: "master"}, "refs/heads/master", hashes[1]},
+		{engine.CheckoutOptions{Branch: "master", Ref: "v1.0.0"}, "refs/tags/v1.0.0", hashes[0]},
+		{engine.CheckoutOptions{Branch: "master", Ref: "pull/7/head"}, "refs/pull/7/head", hashes[2]},
+		{engine.CheckoutOptions{Branch: "master", Commit: hashes[0]}, "", hashes[0]},
+	}
+
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "checkout")
+		co, err := cache.Checkout(src, &tt.opts, dir)
+		cache.Release(src)
+		if err != nil {
+			t.Errorf("Checkout of %v failed: %v\n", tt.opts, err.Error())
+			continue
+		}
+
+		if co.Ref != tt.ref || co.Commit != tt.commit {
+			t.Errorf("Expected %v at %v for %v. Got %v at %v\n", tt.ref, tt.commit, tt.opts, co.Ref, co.Commit)
+		}
+
+		if head, err := engine.HeadCommit(dir); err != nil || head != tt.commit {
+			t.Errorf("Expected %v to be checked out for %v. Got %v\n", tt.commit, tt.opts, head)
+		}
+	}
+
+	// the mirror holds the pull request, but it is not part of master
+	opts := engine.CheckoutOptions{Branch: "master", Ref: "master", Commit: hashes[2]}
+	if _, err := cache.Checkout(src, &opts, filepath.Join(t.TempDir(), "checkout")); err == nil {
+		t.Errorf("Expected failure for a commit outside of the ref, but error not returned.\n")
+	}
+	cache.Release(src)
+}
+
+func TestMirrorCacheFetchesIncrementally(t *testing.T) {
+	src, hashes := makeGitRepo(t, []map[string]string{
+		{"a.txt": "one\n"},
+	})
+
+	cacheDir := t.TempDir()
+	cache, err := engine.NewMirrorCache(cacheDir, 0)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	co, err := cache.Checkout("file://"+src, &engine.CheckoutOptions{Branch: "master"}, dir)
+	cache.Release("file://" + src)
+	if err != nil || co.Commit != hashes[0] {
+		t.Fatalf("Expected commit %v to be checked out. Got %v, %v\n", hashes[0], co, err)
+	}
+
+	// add a commit to the source, which the next checkout must see
+	head := appendCommit(t, src, "b.txt", "two\n")
+
+	dir = filepath.Join(t.TempDir(), "checkout")
+	co, err = cache.Checkout("file://"+src, &engine.CheckoutOptions{Branch: "master"}, dir)
+	cache.Release("file://" + src)
+	if err != nil || co.Commit != head {
+		t.Fatalf("Expected commit %v to be checked out. Got %v, %v\n", head, co, err)
+	}
+
+	for name, expected := range map[string]string{"a.txt": "one\n", "b.txt": "two\n"} {
+		if contents, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(contents) != expected {
+			t.Errorf("Expected %v to contain %q. Got %q\n", name, expected, contents)
+		}
+	}
+
+	if n := cachedMirrors(t, cacheDir); n != 1 {
+		t.Errorf("Expected a single mirror in the cache. Got %v\n", n)
+	}
+
+	// a new cache reuses the mirrors left in the directory
+	cache, err = engine.NewMirrorCache(cacheDir, 0)
+	if err != nil {
+		t.Fatalf("Could not reopen mirror cache: %v\n", err.Error())
+	}
+
+	dir = filepath.Join(t.TempDir(), "checkout")
+	co, err = cache.Checkout("file://"+src, &engine.CheckoutOptions{Branch: "master", Commit: hashes[0]}, dir)
+	cache.Release("file://" + src)
+	if err != nil || co.Commit != hashes[0] {
+		t.Errorf("Expected commit %v to be checked out. Got %v, %v\n", hashes[0], co, err)
+	}
+}
+
+func TestMirrorCacheConcurrentCheckouts(t *testing.T) {
+	src, hashes := makeGitRepo(t, []map[string]string{
+		{"a.txt": "one\n"},
+		{"a.txt": "two\n"},
+	})
+
+	cacheDir := t.TempDir()
+	cache, err := engine.NewMirrorCache(cacheDir, 0)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cache.Release("file://" + src)
+
+			dir := filepath.Join(t.TempDir(), "checkout")
+			co, err := cache.Checkout("file://"+src, &engine.CheckoutOptions{Branch: "master"}, dir)
+			if err == nil && co.Commit != hashes[1] {
+				t.Errorf("Expected commit %v. Got %v\n", hashes[1], co.Commit)
+			}
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent checkout failed: %v\n", err.Error())
+		}
+	}
+
+	if n := cachedMirrors(t, cacheDir); n != 1 {
+		t.Errorf("Expected a single mirror in the cache. Got %v\n", n)
+	}
+}
+
+func TestMirrorCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	commits := []map[string]string{{"a.txt": "one\n"}}
+	first, _ := makeGitRepo(t, commits)
+	second, _ := makeGitRepo(t, commits)
+
+	// both repositories have the same contents, hence mirrors of the
+	// same size
+	sizeDir := t.TempDir()
+	cache, _ := engine.NewMirrorCache(sizeDir, 0)
+	cache.Checkout(first, &engine.CheckoutOptions{Branch: "master"}, filepath.Join(t.TempDir(), "checkout"))
+	cache.Release(first)
+	size := cacheSize(t, sizeDir)
+
+	cacheDir := t.TempDir()
+	cache, err := engine.NewMirrorCache(cacheDir, size+size/2)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	for _, src := range []string{first, second} {
+		if _, err := cache.Checkout(src, &engine.CheckoutOptions{Branch: "master"}, filepath.Join(t.TempDir(), "checkout")); err != nil {
+			t.Fatalf("Checkout of %v failed: %v\n", src, err.Error())
+		}
+		cache.Release(src)
+	}
+
+	if n := cachedMirrors(t, cacheDir); n != 1 {
+		t.Errorf("Expected the least recently used mirror to be evicted. Got %v mirrors\n", n)
+	}
+
+	// mirrors in use are kept, even over the quota
+	for _, src := range []string{first, second} {
+		if _, err := cache.Checkout(src, &engine.CheckoutOptions{Branch: "master"}, filepath.Join(t.TempDir(), "checkout")); err != nil {
+			t.Fatalf("Checkout of %v failed: %v\n", src, err.Error())
+		}
+	}
+
+	if n := cachedMirrors(t, cacheDir); n != 2 {
+		t.Errorf("Expected mirrors in use to be kept. Got %v mirrors\n", n)
+	}
+
+	cache.Release(first)
+	cache.Release(second)
+	if n := cachedMirrors(t, cacheDir); n != 1 {
+		t.Errorf("Expected the cache to fit its quota after release. Got %v mirrors\n", n)
+	}
+}
+
+func TestMirrorCacheMaxSize(t *testing.T) {
+	// zeros compress well, so the mirror stays small while the
+	// checked out file does not
+	src, _ := makeGitRepo(t, []map[string]string{{"zeros.bin": strings.Repeat("\x00", 1024*1024)}})
+
+	cache, err := engine.NewMirrorCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	_, err = cache.Checkout(src, &engine.CheckoutOptions{Branch: "master", MaxSize: 256 * 1024}, dir)
+	cache.Release(src)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size of 262144 bytes") {
+		t.Errorf("Expected the size limit to apply to the checkout. Got %v\n", err)
+	}
+
+	dir = filepath.Join(t.TempDir(), "checkout")
+	_, err = cache.Checkout(src, &engine.CheckoutOptions{Branch: "master", MaxSize: 4 * 1024 * 1024}, dir)
+	cache.Release(src)
+	if err != nil {
+		t.Errorf("Expected the checkout to fit in the limit: %v\n", err.Error())
+	}
+}
+
+func TestMirrorCacheMaxSizeOfLargeMirror(t *testing.T) {
+	// random data does not compress, so the history stays large
+	data := make([]byte, 512*1024)
+	rand.Read(data)
+	src, _ := makeGitRepo(t, []map[string]string{{"blob.bin": string(data)}, {"blob.bin": ""}})
+
+	cache, err := engine.NewMirrorCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	_, err = cache.Checkout(src, &engine.CheckoutOptions{Branch: "master"}, filepath.Join(t.TempDir(), "checkout"))
+	cache.Release(src)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v\n", err.Error())
+	}
+
+	// only what the later scans fetch counts towards their limit
+	head := appendCommit(t, src, "a.txt", "one\n")
+	co, err := cache.Checkout(src, &engine.CheckoutOptions{Branch: "master", MaxSize: 64 * 1024}, filepath.Join(t.TempDir(), "checkout"))
+	cache.Release(src)
+	if err != nil {
+		t.Fatalf("Expected the checkout to fit in the limit once the mirror is large: %v\n", err.Error())
+	}
+	if co.Commit != head {
+		t.Errorf("Expected commit %v to be checked out. Got %v\n", head, co.Commit)
+	}
+}
@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"regexp"
+	"strings"
+)
+
+// Matches the armor header of PEM, OpenSSH and PGP private keys. The
+// first group holds the label that must be repeated in the footer.
+var keyBlockBegin = regexp.MustCompile(`-----BEGIN ((?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?)-----`)
+
+const (
+	// Blocks with less encoded data than this are assumed to be
+	// parsers or documentation mentioning the armor, not real keys.
+	minKeyBlockData = 64
+	// Blocks larger than this are abandoned.
+	maxKeyBlockData = 1 << 20
+)
+
+// Analyzer that reports private key blocks spanning one or more lines.
+// Encrypted keys are reported under a separate rule so that they can
+// be given a lower severity.
+type privateKeyAnalyzer struct {
+	fs        *fileScan
+	plain     *RuleDefinition
+	encrypted *RuleDefinition
+	block     *keyBlock
+}
+
+// A private key block that has been opened but not yet closed.
+type keyBlock struct {
+	label string
+	begin position
+	// the armor header, shown as the context of the finding
+	header string
+	body   []string
+	size   int
+}
+
+func newPrivateKeyAnalyzer(fs *fileScan, plain, encrypted *RuleDefinition) *privateKeyAnalyzer {
+	return &privateKeyAnalyzer{
+		fs:        fs,
+		plain:     plain,
+		encrypted: encrypted,
+	}
+}
+
+func (pa *privateKeyAnalyzer) scanLine(line string, num int) {
+	// rest starts at index at of the line
+	rest, at := line, 0
+	for {
+		if pa.block == nil {
+			loc := keyBlockBegin.FindStringSubmatchIndex(rest)
+			if loc == nil {
+				return
+			}
+			pa.block = &keyBlock{
+				label:  rest[loc[2]:loc[3]],
+				begin:  pa.fs.position(num, at+loc[0]),
+				header: rest[loc[0]:loc[1]],
+			}
+			rest, at = rest[loc[1]:], at+loc[1]
+		}
+
+		footer := "-----END " + pa.block.label + "-----"
+		i := strings.Index(rest, footer)
+		if i < 0 {
+			pa.block.add(rest)
+			if pa.block.size > maxKeyBlockData {
+				pa.block = nil
+			}
+			return
+		}
+
+		pa.block.add(rest[:i])
+		pa.closeBlock(pa.fs.position(num, at+i+len(footer)))
+		rest, at = rest[i+len(footer):], at+i+len(footer)
+	}
+}
+
+// Unterminated blocks are not reported.
+func (pa *privateKeyAnalyzer) finish() {
+	pa.block = nil
+}
+
+// Helper function to report the current block, ending at the given
+// position, under the rule matching its encryption state.
+func (pa *privateKeyAnalyzer) closeBlock(end position) {
+	kb := pa.block
+	pa.block = nil
+
+	data := kb.data()
+	if len(data) < minKeyBlockData {
+		return
+	}
+
+	rd := pa.plain
+	if kb.isEncrypted(data) {
+		rd = pa.encrypted
+	}
+	if rd == nil {
+		return
+	}
+
+	pa.fs.report(&match{
+		rule:    rd,
+		begin:   kb.begin,
+		end:     end,
+		secret:  data,
+		context: kb.header,
+	})
+}
+
+// Helper function to add the text found between the armor lines to
+// the block. Keys embedded in string literals use escaped newlines and
+// may be wrapped in quotes or concatenation operators.
+func (kb *keyBlock) add(text string) {
+	text = strings.ReplaceAll(text, `\r\n`, `\n`)
+	for _, part := range strings.Split(text, `\n`) {
+		part = strings.Trim(part, " \t\r\"'`,;+\\")
+		if part != "" {
+			kb.body = append(kb.body, part)
+			kb.size += len(part)
+		}
+	}
+}
+
+// Helper function to return the base64 encoded payload of the block,
+// skipping armor headers and the PGP checksum.
+func (kb *keyBlock) data() string {
+	var sb strings.Builder
+	for _, part := range kb.body {
+		if strings.Contains(part, ":") {
+			continue
+		}
+		if strings.HasPrefix(part, "=") && len(part) <= 5 {
+			continue
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// Helper function to determine whether the key in the block is
+// protected by a passphrase. Keys that cannot be decoded are treated
+// as unencrypted.
+func (kb *keyBlock) isEncrypted(data string) bool {
+	if strings.Contains(kb.label, "ENCRYPTED") {
+		return true
+	}
+
+	for _, part := range kb.body {
+		if strings.HasPrefix(part, "Proc-Type:") && strings.Contains(part, "ENCRYPTED") {
+			return true
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return false
+	}
+
+	switch kb.label {
+	case "OPENSSH PRIVATE KEY":
+		return openSSHKeyEncrypted(raw)
+	case "PGP PRIVATE KEY BLOCK":
+		return pgpKeyEncrypted(raw)
+	}
+
+	return false
+}
+
+// Helper function to read the cipher name of an OpenSSH private key.
+func openSSHKeyEncrypted(raw []byte) bool {
+	magic := []byte("openssh-key-v1\x00")
+	if !bytes.HasPrefix(raw, magic) {
+		return false
+	}
+
+	raw = raw[len(magic):]
+	if len(raw) < 4 {
+		return false
+	}
+
+	n := binary.BigEndian.Uint32(raw)
+	if uint32(len(raw)-4) < n {
+		return false
+	}
+
+	return string(raw[4:4+n]) != "none"
+}
+
+// Helper function to read the string-to-key usage octet of the first
+// secret key packet of a PGP private key (RFC 4880 section 5.5.3).
+func pgpKeyEncrypted(raw []byte) bool {
+	if len(raw) < 2 || raw[0]&0x80 == 0 {
+		return false
+	}
+
+	var tag byte
+	var pos int
+	if raw[0]&0x40 != 0 {
+		// new packet format
+		tag = raw[0] & 0x3f
+		switch l := raw[1]; {
+		case l < 192 || l >= 224 && l < 255:
+			pos = 2
+		case l < 224:
+			pos = 3
+		default:
+			pos = 6
+		}
+	} else {
+		// old packet format
+		tag = (raw[0] >> 2) & 0x0f
+		pos = []int{2, 3, 5, 1}[raw[0]&0x03]
+	}
+
+	// secret key packet
+	if tag != 5 || len(raw) <= pos {
+		return false
+	}
+
+	p := raw[pos:]
+	switch p[0] {
+	case 4:
+		pos = 6
+	case 2, 3:
+		pos = 8
+	default:
+		return false
+	}
+	if len(p) <= pos {
+		return false
+	}
+
+	algo := p[pos-1]
+	skipMPI := func(n int) bool {
+		for ; n > 0; n-- {
+			if len(p) < pos+2 {
+				return false
+			}
+			bits := int(binary.BigEndian.Uint16(p[pos:]))
+			pos += 2 + (bits+7)/8
+		}
+		return len(p) > pos
+	}
+	skipField := func() bool {
+		if len(p) <= pos {
+			return false
+		}
+		pos += 1 + int(p[pos])
+		return len(p) > pos
+	}
+
+	ok := false
+	switch algo {
+	case 1, 2, 3:
+		// RSA
+		ok = skipMPI(2)
+	case 16:
+		// Elgamal
+		ok = skipMPI(3)
+	case 17:
+		// DSA
+		ok = skipMPI(4)
+	case 19, 22:
+		// ECDSA, EdDSA
+		ok = skipField() && skipMPI(1)
+	case 18:
+		// ECDH
+		ok = skipField() && skipMPI(1) && skipField()
+	}
+
+	return ok && p[pos] != 0
+}
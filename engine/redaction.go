@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Number of characters of the secret left visible at each end of
+	// its snippet.
+	snippetReveal = 4
+	// Secrets shorter than this are masked completely.
+	minRevealLength = 3 * snippetReveal
+	// The masked part of long secrets, such as private keys, is cut
+	// to this many characters.
+	maxSnippetMask = 32
+	// Longer contexts are cut around the redacted secret.
+	maxContextLength = 200
+)
+
+// Helper function to mask the secret, leaving only its first and last
+// few characters visible if it is long enough for that to be safe.
+func redact(secret string) string {
+	if len(secret) < minRevealLength {
+		return strings.Repeat("*", len(secret))
+	}
+	mask := len(secret) - 2*snippetReveal
+	if mask > maxSnippetMask {
+		mask = maxSnippetMask
+	}
+	head := runeStart(secret, snippetReveal, false)
+	tail := runeStart(secret, len(secret)-snippetReveal, true)
+	return secret[:head] + strings.Repeat("*", mask) + secret[tail:]
+}
+
+// Helper function to move index i of the text to the start of the
+// character it falls in, or of the next one, so that the text can be
+// cut there without splitting a character.
+func runeStart(text string, i int, next bool) int {
+	for i > 0 && i < len(text) && !utf8.RuneStart(text[i]) {
+		if next {
+			i++
+		} else {
+			i--
+		}
+	}
+	return i
+}
+
+// Helper function to normalize the secret before it is fingerprinted,
+// so that quoting and surrounding whitespace do not matter.
+func normalizeSecret(secret string) string {
+	return strings.Trim(strings.TrimSpace(secret), "\"'`")
+}
+
+// Helper function to compute the fingerprint of a finding, which stays
+// the same as long as the same secret is found by the same rule in the
+// same file, wherever it moves within that file.
+func fingerprint(ruleId, secret, path string) string {
+	h := sha256.New()
+	h.Write([]byte(ruleId))
+	h.Write([]byte{0})
+	h.Write([]byte(normalizeSecret(secret)))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Helper function to build the context of a finding from the text that
+// matched. The secret is redacted along with the other secrets of the
+// file that appear in the text, and long text is cut around it.
+func lineContext(text, secret string, others []string) string {
+	snippet := redact(secret)
+
+	// longer secrets go first, so that no part of a secret holding
+	// another one is left in clear
+	secrets := append([]string{secret}, others...)
+	sort.SliceStable(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, s := range secrets {
+		if s != "" {
+			text = strings.ReplaceAll(text, s, redact(s))
+		}
+	}
+	text = strings.TrimSpace(text)
+	if len(text) <= maxContextLength {
+		return text
+	}
+
+	start := strings.Index(text, snippet) - (maxContextLength-len(snippet))/2
+	if start < 0 {
+		start = 0
+	}
+	if start > len(text)-maxContextLength {
+		start = len(text) - maxContextLength
+	}
+	end := runeStart(text, start+maxContextLength, false)
+	start = runeStart(text, start, true)
+	return text[start:end]
+}
@@ -0,0 +1,92 @@
+package engine
+
+import "strings"
+
+// Analyzer that runs the regular expressions of the rule pack against
+// every line of a file.
+type regexAnalyzer struct {
+	fs      *fileScan
+	rules   []*RuleDefinition
+	pending map[string]*pendingMatch
+}
+
+// A line that matched the prefix of a rule without its secret token.
+// It is joined with the following line to try the rule again.
+type pendingMatch struct {
+	text   string
+	pieces []textPiece
+}
+
+// The start of a line, or of a chunk of a line, within text that
+// joins several of them.
+type textPiece struct {
+	index int
+	pos   position
+}
+
+func newRegexAnalyzer(fs *fileScan, rules []*RuleDefinition) *regexAnalyzer {
+	return &regexAnalyzer{
+		fs:      fs,
+		rules:   rules,
+		pending: make(map[string]*pendingMatch),
+	}
+}
+
+func (ra *regexAnalyzer) scanLine(line string, num int) {
+	// only the lines containing a keyword of a rule go through its
+	// regular expression
+	lower := strings.ToLower(line)
+	piece := textPiece{index: 0, pos: ra.fs.position(num, 0)}
+	for _, rd := range ra.rules {
+		text, lowerText, pieces := line, lower, []textPiece{piece}
+		if p, ok := ra.pending[rd.Id]; ok {
+			text = p.text + line
+			lowerText = strings.ToLower(text)
+			pieces = append(p.pieces, textPiece{index: len(p.text), pos: piece.pos})
+			delete(ra.pending, rd.Id)
+		}
+
+		if !rd.hasKeywordLower(lowerText) {
+			continue
+		}
+
+		for _, m := range rd.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*rd.secretGroup], m[2*rd.secretGroup+1]
+			if start < 0 || start == end {
+				// prefix found, but token not found
+				// buffer it and see if the token appears later
+				ra.pending[rd.Id] = &pendingMatch{text: text, pieces: pieces}
+				continue
+			}
+
+			// matches spanning several lines begin with their prefix
+			begin := locate(pieces, start)
+			if first := locate(pieces, m[0]); first.line < begin.line {
+				begin = first
+			}
+
+			ra.fs.report(&match{
+				rule:    rd,
+				begin:   begin,
+				end:     locate(pieces, end-1).advance(1),
+				secret:  text[start:end],
+				context: text,
+			})
+		}
+	}
+}
+
+func (ra *regexAnalyzer) finish() {}
+
+// Helper function to return the position of the byte at index i of
+// text made of the given pieces.
+func locate(pieces []textPiece, i int) position {
+	p := pieces[0]
+	for _, next := range pieces[1:] {
+		if next.index > i {
+			break
+		}
+		p = next
+	}
+	return p.pos.advance(i - p.index)
+}
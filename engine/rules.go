@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Severity levels that can be assigned to a rule.
+var severityLevels = []string{"HIGH", "MEDIUM", "LOW", "INFORMATIONAL"}
+
+// Analyzers that can produce findings for a rule. Rules without an
+// analyzer use their regular expression.
+const (
+	analyzerRegex               = "regex"
+	analyzerPrivateKey          = "private-key"
+	analyzerEncryptedPrivateKey = "encrypted-private-key"
+	analyzerEntropy             = "entropy"
+	analyzerStructured          = "structured"
+)
+
+var analyzers = []string{analyzerRegex, analyzerPrivateKey, analyzerEncryptedPrivateKey, analyzerEntropy, analyzerStructured}
+
+type RuleDefinition struct {
+	Id          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Severity    string   `yaml:"severity"`
+	Confidence  string   `yaml:"confidence"`
+	Analyzer    string   `yaml:"analyzer"`
+	Verifier    string   `yaml:"verifier"`
+	Regex       string   `yaml:"regex"`
+	Keywords    []string `yaml:"keywords"`
+	Files       []string `yaml:"files"`
+	Enabled     *bool    `yaml:"enabled"`
+
+	re          *regexp.Regexp
+	secretGroup int
+	keywords    []string
+}
+
+// IsEnabled reports whether the rule is turned on. Rules are enabled
+// unless explicitly disabled in the rule pack.
+func (rd *RuleDefinition) IsEnabled() bool {
+	return rd.Enabled == nil || *rd.Enabled
+}
+
+// AppliesTo reports whether the rule should be run against the file
+// with the given path, relative to the root of the scanned tree.
+// Patterns without a '/' are matched against the file name only.
+func (rd *RuleDefinition) AppliesTo(relpath string) bool {
+	if len(rd.Files) == 0 {
+		return true
+	}
+
+	relpath = filepath.ToSlash(strings.TrimPrefix(relpath, string(filepath.Separator)))
+	for _, pattern := range rd.Files {
+		target := relpath
+		if !strings.Contains(pattern, "/") {
+			target = filepath.Base(relpath)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Helper function to overwrite the fields of the rule with those that
+// are set in the other rule.
+func (rd *RuleDefinition) merge(o *RuleDefinition) {
+	if o.Description != "" {
+		rd.Description = o.Description
+	}
+	if o.Severity != "" {
+		rd.Severity = o.Severity
+	}
+	if o.Confidence != "" {
+		rd.Confidence = o.Confidence
+	}
+	if o.Analyzer != "" {
+		rd.Analyzer = o.Analyzer
+	}
+	if o.Verifier != "" {
+		rd.Verifier = o.Verifier
+	}
+	if o.Regex != "" {
+		rd.Regex = o.Regex
+	}
+	if o.Keywords != nil {
+		rd.Keywords = o.Keywords
+	}
+	if o.Files != nil {
+		rd.Files = o.Files
+	}
+	if o.Enabled != nil {
+		rd.Enabled = o.Enabled
+	}
+}
+
+// Helper function to validate the rule and compile its regular
+// expression.
+func (rd *RuleDefinition) compile() error {
+	if rd.Description == "" {
+		return errors.New("missing description")
+	}
+
+	rd.Severity = strings.ToUpper(rd.Severity)
+	validSeverity := false
+	for _, s := range severityLevels {
+		if rd.Severity == s {
+			validSeverity = true
+		}
+	}
+	if !validSeverity {
+		return fmt.Errorf("invalid severity '%v'", rd.Severity)
+	}
+
+	// rules are of medium confidence unless told otherwise
+	if rd.Confidence == "" {
+		rd.Confidence = "MEDIUM"
+	}
+	confidence, err := ParseConfidence(rd.Confidence)
+	if err != nil {
+		return err
+	}
+	rd.Confidence = confidence
+
+	for _, pattern := range rd.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern '%v'", pattern)
+		}
+	}
+
+	rd.keywords = make([]string, 0, len(rd.Keywords))
+	for _, kw := range rd.Keywords {
+		if kw != "" {
+			rd.keywords = append(rd.keywords, strings.ToLower(kw))
+		}
+	}
+
+	if rd.Analyzer == "" {
+		rd.Analyzer = analyzerRegex
+	}
+
+	validAnalyzer := false
+	for _, an := range analyzers {
+		if rd.Analyzer == an {
+			validAnalyzer = true
+		}
+	}
+	if !validAnalyzer {
+		return fmt.Errorf("unknown analyzer '%v'", rd.Analyzer)
+	}
+
+	// the keywords of structured rules are matched against key names,
+	// so a rule without any would report every value
+	if rd.Analyzer == analyzerStructured && len(rd.keywords) == 0 {
+		return errors.New("missing keywords")
+	}
+
+	if rd.Analyzer != analyzerRegex {
+		return nil
+	}
+
+	if rd.Regex == "" {
+		return errors.New("missing regex")
+	}
+
+	re, err := regexp.Compile(rd.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %v", err.Error())
+	}
+	rd.re = re
+
+	// The secret token is taken from the group named 'secret' if there
+	// is one, otherwise the whole match is used.
+	rd.secretGroup = re.SubexpIndex("secret")
+	if rd.secretGroup < 0 {
+		rd.secretGroup = 0
+	}
+
+	return nil
+}
+
+// Helper function to cheaply check whether a line could match the
+// rule before running the regular expression.
+func (rd *RuleDefinition) hasKeyword(line string) bool {
+	if len(rd.keywords) == 0 {
+		return true
+	}
+
+	return rd.hasKeywordLower(strings.ToLower(line))
+}
+
+// Same as hasKeyword, for a line that is already in lower case. Lets
+// the caller lower a line once for the whole rule set.
+func (rd *RuleDefinition) hasKeywordLower(lower string) bool {
+	if len(rd.keywords) == 0 {
+		return true
+	}
+
+	for _, kw := range rd.keywords {
+		if strings.Contains(lower, kw) {
+			return true
+		}
+	}
+
+	return false
+}
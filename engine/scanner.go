@@ -16,6 +16,9 @@ type Scanner struct {
 	jobBoardLock sync.RWMutex
 	jobBoardOpen bool
 	noop         bool
+	config       *Config
+	mirrors      *MirrorCache
+	localRoots   []string
 }
 
 func (s *Scanner) Initialize(limit int, noop bool) {
@@ -27,6 +30,24 @@ func (s *Scanner) Initialize(limit int, noop bool) {
 	s.jobBoardLock = sync.RWMutex{}
 	s.jobBoardOpen = true
 	s.noop = noop
+	s.config = DefaultConfig()
+}
+
+// Replace the rule configuration used by subsequent scans.
+func (s *Scanner) Configure(cfg *Config) {
+	s.config = cfg
+}
+
+// Check out repositories from the given cache of mirrors in subsequent
+// scans, instead of cloning them in full.
+func (s *Scanner) UseMirrorCache(c *MirrorCache) {
+	s.mirrors = c
+}
+
+// Allow repositories to be scanned from the local directories located
+// under the given roots.
+func (s *Scanner) AllowLocalPaths(roots []string) {
+	s.localRoots = roots
 }
 
 func (s *Scanner) CleanUp() {
@@ -79,6 +100,7 @@ func (s *Scanner) StartScan(j *Job) {
 			j.Result <- &JobUpdate{
 				Status:   "FAILURE",
 				Findings: nil,
+				Reason:   err.Error(),
 			}
 		}()
 	}
@@ -109,23 +131,33 @@ func (s *Scanner) Work(id string) {
 	}
 
 	log.Println("Scanner starting repository download from url.")
-	var checkoutDir string
+	var checkoutDir, ref string
+	var co *Checkout
 	if s.noop {
 		<-time.NewTimer(1 * time.Second).C
 	} else {
 		// Make temp directory
-		if path, err := ioutil.TempDir("", "reposcanner"); err != nil {
+		scratchDir, err := ioutil.TempDir("", "reposcanner")
+		if err != nil {
 			log.Printf("failed to create checkout directory: %v", err.Error())
-			s.endJobWithFailure(j)
-		} else {
-			checkoutDir = path
+			s.endJobWithFailure(j, "failed to create checkout directory")
+			return
 		}
-		defer DeleteTmpDirectory(checkoutDir)
+		defer DeleteTmpDirectory(scratchDir)
+
+		src := s.source(j)
+		defer src.Release()
 
 		// Download url
-		if err := CloneRepository(j.Repo.Url, j.Repo.Branch, checkoutDir); err != nil {
+		if snap, err := src.Fetch(scratchDir); err != nil {
 			log.Printf("failed to download repository: %v", err.Error())
-			s.endJobWithFailure(j)
+			s.endJobWithFailure(j, err.Error())
+			return
+		} else {
+			checkoutDir, co = snap.Dir, snap.Checkout
+		}
+		if co != nil {
+			ref = co.Ref
 		}
 	}
 
@@ -136,6 +168,8 @@ func (s *Scanner) Work(id string) {
 
 	log.Println("Scanner starting repository scan.")
 	var findings []*models.FindingsInfo
+	var skipped []*models.SkippedFile
+	var commit, configHash string
 	if s.noop {
 		<-time.NewTimer(1 * time.Second).C
 
@@ -173,8 +207,64 @@ func (s *Scanner) Work(id string) {
 		}
 	} else {
 		var sf SecretFinder
-		sf.Initialize()
-		findings = sf.FindSecrets(checkoutDir)
+		sf.InitializeWithConfig(s.config)
+		sf.SetExcludes(j.Repo.Excludes)
+
+		if j.Repo.SubmoduleDepth > 0 && co != nil {
+			if subs, err := ListSubmodules(checkoutDir); err != nil {
+				log.Printf("failed to list submodules: %v", err.Error())
+			} else {
+				sf.SetSubmodules(subs)
+			}
+		}
+
+		mode := ""
+		if j.Request != nil {
+			mode = j.Request.Mode
+		}
+
+		// sources other than git only have a tree to scan
+		var err error
+		if co != nil {
+			if commit, err = HeadCommit(checkoutDir); err != nil {
+				log.Printf("failed to read checked out commit: %v", err.Error())
+				s.endJobWithFailure(j, "failed to read checked out commit")
+				return
+			}
+		} else if mode != "" && mode != "tree" {
+			s.endJobWithFailure(j, mode+" scans require a git repository")
+			return
+		}
+
+		switch mode {
+		case "history":
+			if findings, err = sf.FindSecretsInHistory(checkoutDir, j.Request.History); err != nil {
+				log.Printf("failed to scan repository history: %v", err.Error())
+				s.endJobWithFailure(j, "failed to scan repository history: "+err.Error())
+				return
+			}
+		case "incremental":
+			if findings, err = sf.FindSecretsSince(checkoutDir, j.Baseline); err != nil {
+				log.Printf("failed to scan repository changes: %v", err.Error())
+				s.endJobWithFailure(j, "failed to scan repository changes: "+err.Error())
+				return
+			}
+		default:
+			findings = sf.FindSecrets(checkoutDir)
+		}
+
+		// history scans know the commit of each finding already
+		if mode != "history" && co != nil {
+			if err := BlameFindings(checkoutDir, findings); err != nil {
+				log.Printf("failed to attribute findings: %v", err.Error())
+			}
+		}
+		skipped = sf.Skipped()
+		configHash = sf.ConfigHash(checkoutDir)
+
+		if n := len(sf.Discarded()); n > 0 {
+			log.Printf("Scanner discarded %v likely false positives.", n)
+		}
 	}
 
 	// Check for cancellation
@@ -184,17 +274,68 @@ func (s *Scanner) Work(id string) {
 
 	// Send results
 	j.Result <- &JobUpdate{
-		Status:   "SUCCESS",
-		Findings: findings,
+		Status:     "SUCCESS",
+		Findings:   findings,
+		Skipped:    skipped,
+		Commit:     commit,
+		Ref:        ref,
+		ConfigHash: configHash,
 	}
 
 	s.removeFromJobBoard(id)
 }
 
-func (s *Scanner) endJobWithFailure(j *Job) {
+// Helper function to build the checkout options of a job from its
+// repository and request. Shallow clones are only used for tree scans,
+// as the other modes need the history of the branch.
+func checkoutOptions(j *Job) *CheckoutOptions {
+	opts := &CheckoutOptions{
+		Branch:         j.Repo.Branch,
+		SubmoduleDepth: int(j.Repo.SubmoduleDepth),
+		Credential:     j.Credential,
+	}
+
+	mode := ""
+	if j.Request != nil {
+		opts.Ref, opts.Commit = j.Request.Ref, j.Request.Commit
+		mode = j.Request.Mode
+	}
+
+	if co := j.Repo.CloneOptions; co != nil {
+		if mode == "" || mode == "tree" {
+			opts.Depth = int(co.Depth)
+		}
+		opts.SparsePaths = co.SparsePaths
+		opts.SingleBranch = co.SingleBranch
+		opts.MaxSize = co.MaxSize
+		opts.Timeout = time.Duration(co.Timeout) * time.Second
+	}
+
+	return opts
+}
+
+// Helper function to return the source of the files scanned by the
+// job, depending on the kind of repository.
+func (s *Scanner) source(j *Job) Source {
+	switch j.Repo.Source {
+	case SourceArchive:
+		as := &ArchiveSource{Path: j.Archive}
+		if j.Repo.CloneOptions != nil {
+			as.MaxSize = j.Repo.CloneOptions.MaxSize
+		}
+		return as
+	case SourcePath:
+		return &LocalSource{Path: j.Repo.Url, Roots: s.localRoots}
+	default:
+		return &GitSource{Url: j.Repo.Url, Options: checkoutOptions(j), Mirrors: s.mirrors}
+	}
+}
+
+func (s *Scanner) endJobWithFailure(j *Job, reason string) {
 	j.Result <- &JobUpdate{
 		Status:   "FAILURE",
 		Findings: nil,
+		Reason:   reason,
 	}
 
 	s.removeFromJobBoard(j.Id)
@@ -2,6 +2,8 @@ package engine_test
 
 import (
 	"log"
+	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -200,3 +202,234 @@ func TestScannerWorksOnRealJobNotFoundUrl(t *testing.T) {
 		t.Fatalf("Expected to receive job status change, but timed out.\n")
 	}
 }
+
+// Helper function to wait for the final update of a job, failing the
+// test if the job does not complete in time.
+func waitForJobResult(t *testing.T, results chan *engine.JobUpdate) *engine.JobUpdate {
+	timeout := time.NewTimer(10 * time.Second)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case r := <-results:
+			if r.Status != "ONGOING" {
+				return r
+			}
+		case <-timeout.C:
+			t.Fatalf("Expected to receive job result, but timed out.\n")
+			return nil
+		}
+	}
+}
+
+func TestScannerWorksOnHistoryJob(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.env": "private_key=abcdef\n"},
+		{"a.env": "name=app\n"},
+	})
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+
+	results := make(chan *engine.JobUpdate)
+	j := &engine.Job{
+		Id:      "A",
+		Repo:    &models.RepositoryInfo{Name: "local", Url: dir, Branch: "master"},
+		Request: &models.ScanRequest{Mode: "history"},
+		Result:  results,
+	}
+
+	s.StartScan(j)
+	r := waitForJobResult(t, results)
+
+	if r.Status != "SUCCESS" {
+		t.Fatalf("Expected job status to be SUCCESS. Got %v\n", r.Status)
+	}
+	if len(r.Findings) != 1 || r.Findings[0].Commit == nil || r.Findings[0].Commit.Sha != hashes[0] {
+		t.Errorf("Expected one finding from the first commit. Got %v\n", r.Findings)
+	}
+
+	if r.Commit != hashes[1] {
+		t.Errorf("Expected scanned commit %v. Got %v\n", hashes[1], r.Commit)
+	}
+}
+
+func TestScannerWorksOnIncrementalJob(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.env": "private_key=abcdef\n"},
+		{"b.env": "public_key=ghijkl\n"},
+	})
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+
+	// the baseline was scanned with the same rules
+	var sf engine.SecretFinder
+	sf.Initialize()
+	configHash := sf.ConfigHash(dir)
+
+	results := make(chan *engine.JobUpdate)
+	j := &engine.Job{
+		Id:      "A",
+		Repo:    &models.RepositoryInfo{Name: "local", Url: dir, Branch: "master"},
+		Request: &models.ScanRequest{Mode: "incremental"},
+		Baseline: &engine.Baseline{
+			Commit:     hashes[0],
+			Findings:   []*models.FindingsInfo{},
+			ConfigHash: configHash,
+		},
+		Result: results,
+	}
+
+	s.StartScan(j)
+	r := waitForJobResult(t, results)
+
+	if r.Status != "SUCCESS" {
+		t.Fatalf("Expected job status to be SUCCESS. Got %v\n", r.Status)
+	}
+	if len(r.Findings) != 1 || r.Findings[0].Location.Path != "/b.env" {
+		t.Errorf("Expected one finding in the changed file. Got %v\n", r.Findings)
+	}
+	if r.ConfigHash != configHash {
+		t.Errorf("Expected the hash of the rules to be reported. Got %v\n", r.ConfigHash)
+	}
+	if r.Commit != hashes[1] {
+		t.Errorf("Expected scanned commit %v. Got %v\n", hashes[1], r.Commit)
+	}
+}
+
+func TestScannerFailsOnInvalidHistoryRange(t *testing.T) {
+	dir, _ := makeGitRepo(t, []map[string]string{{"a.env": "name=app\n"}})
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+
+	results := make(chan *engine.JobUpdate)
+	j := &engine.Job{
+		Id:   "A",
+		Repo: &models.RepositoryInfo{Name: "local", Url: dir, Branch: "master"},
+		Request: &models.ScanRequest{
+			Mode:    "history",
+			History: &models.HistoryRange{From: "not-a-revision"},
+		},
+		Result: results,
+	}
+
+	s.StartScan(j)
+	if r := waitForJobResult(t, results); r.Status != "FAILURE" {
+		t.Errorf("Expected job status to be FAILURE. Got %v\n", r.Status)
+	}
+}
+
+func TestScannerWorksOnShallowJob(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.env": "private_key=abcdef\n"},
+		{"b.env": "name=app\n"},
+	})
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+
+	results := make(chan *engine.JobUpdate)
+	j := &engine.Job{
+		Id: "A",
+		Repo: &models.RepositoryInfo{
+			Name:         "local",
+			Url:          dir,
+			Branch:       "master",
+			CloneOptions: &models.CloneOptions{Depth: 1},
+		},
+		Result: results,
+	}
+
+	s.StartScan(j)
+	r := waitForJobResult(t, results)
+
+	if r.Status != "SUCCESS" {
+		t.Fatalf("Expected job status to be SUCCESS. Got %v (%v)\n", r.Status, r.Reason)
+	}
+	if len(r.Findings) != 1 {
+		t.Fatalf("Expected one finding. Got %v\n", r.Findings)
+	}
+	if r.Commit != hashes[1] {
+		t.Errorf("Expected scanned commit %v. Got %v\n", hashes[1], r.Commit)
+	}
+}
+
+func TestScannerWorksWithMirrorCache(t *testing.T) {
+	dir, hashes := makeGitRepo(t, []map[string]string{
+		{"a.env": "private_key=abcdef\n"},
+		{"b.env": "name=app\n"},
+	})
+
+	cacheDir := t.TempDir()
+	cache, err := engine.NewMirrorCache(cacheDir, 0)
+	if err != nil {
+		t.Fatalf("Could not create mirror cache: %v\n", err.Error())
+	}
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+	s.UseMirrorCache(cache)
+
+	// the second scan reuses the mirror of the first one
+	for _, id := range []string{"A", "B"} {
+		results := make(chan *engine.JobUpdate)
+		j := &engine.Job{
+			Id:     id,
+			Repo:   &models.RepositoryInfo{Name: "local", Url: dir, Branch: "master"},
+			Result: results,
+		}
+
+		s.StartScan(j)
+		r := waitForJobResult(t, results)
+
+		if r.Status != "SUCCESS" {
+			t.Fatalf("Expected job status to be SUCCESS. Got %v (%v)\n", r.Status, r.Reason)
+		}
+		if len(r.Findings) != 1 {
+			t.Fatalf("Expected one finding. Got %v\n", r.Findings)
+		}
+		if r.Commit != hashes[1] {
+			t.Errorf("Expected scanned commit %v. Got %v\n", hashes[1], r.Commit)
+		}
+		if r.Findings[0].Commit == nil || r.Findings[0].Commit.Sha != hashes[0] {
+			t.Errorf("Expected finding to be blamed on %v. Got %v\n", hashes[0], r.Findings[0].Commit)
+		}
+	}
+
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 1 {
+		t.Errorf("Expected a single mirror in the cache. Got %v\n", len(entries))
+	}
+}
+
+func TestScannerReportsFailureReason(t *testing.T) {
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+	dir, _ := makeGitRepo(t, []map[string]string{{"blob.bin": string(data)}})
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+
+	results := make(chan *engine.JobUpdate)
+	j := &engine.Job{
+		Id: "A",
+		Repo: &models.RepositoryInfo{
+			Name:         "local",
+			Url:          dir,
+			Branch:       "master",
+			CloneOptions: &models.CloneOptions{MaxSize: 1024},
+		},
+		Result: results,
+	}
+
+	s.StartScan(j)
+	r := waitForJobResult(t, results)
+
+	if r.Status != "FAILURE" {
+		t.Fatalf("Expected job status to be FAILURE. Got %v\n", r.Status)
+	}
+	if r.Reason != "repository exceeds the maximum size of 1024 bytes" {
+		t.Errorf("Expected the size limit as the reason. Got '%v'\n", r.Reason)
+	}
+}
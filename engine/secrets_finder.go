@@ -2,66 +2,193 @@ package engine
 
 import (
 	"bufio"
+	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 )
 
 type SecretFinder struct {
 	findings []*models.FindingsInfo
 	reportCh chan *models.FindingsInfo
-	ruleDefs map[string]RuleDefinition
+	skipped  []*models.SkippedFile
+	skipCh   chan *models.SkippedFile
+	// matches discarded as false positives
+	discarded []*DiscardedFinding
+	discardCh chan *DiscardedFinding
+	// findings whose secret is verified once the scan completes
+	verifications []*pendingVerification
+	verifyCh      chan *pendingVerification
+	config        *Config
+	basepath      string
+	excludes      []string
+	excluder      gitignore.Matcher
+	// submodules checked out in the scanned tree, innermost first
+	submodules []*models.SubmoduleInfo
+	workers    int
+	queue      chan string
 }
 
-type RuleDefinition struct {
-	Id          string
-	Description string
-	Severity    string
+// Setup the secret finder using the default rule pack.
+func (a *SecretFinder) Initialize() {
+	a.InitializeWithConfig(DefaultConfig())
 }
 
-func (a *SecretFinder) Initialize() {
+// Setup the secret finder using the rules of the given configuration.
+func (a *SecretFinder) InitializeWithConfig(cfg *Config) {
 	a.findings = make([]*models.FindingsInfo, 0)
 	a.reportCh = make(chan *models.FindingsInfo)
+	a.skipped = make([]*models.SkippedFile, 0)
+	a.skipCh = make(chan *models.SkippedFile)
+	a.discarded = make([]*DiscardedFinding, 0)
+	a.discardCh = make(chan *DiscardedFinding)
+	a.verifications = make([]*pendingVerification, 0)
+	a.verifyCh = make(chan *pendingVerification)
+	a.config = cfg
+	a.workers = runtime.NumCPU()
+}
 
-	a.ruleDefs = make(map[string]RuleDefinition)
-	a.ruleDefs["private_key"] = RuleDefinition{
-		Id:          "G001",
-		Description: "Hard-coded secret - private key",
-		Severity:    "HIGH",
-	}
-	a.ruleDefs["public_key"] = RuleDefinition{
-		Id:          "G002",
-		Description: "Hard-coded secret - public key",
-		Severity:    "HIGH",
+// Set the number of files that are scanned concurrently. Values lower
+// than one scan the files sequentially.
+func (a *SecretFinder) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
 	}
+	a.workers = n
+}
+
+// Skipped returns the files that were not scanned by the last scan,
+// along with the reason they were skipped.
+func (a *SecretFinder) Skipped() []*models.SkippedFile {
+	return a.skipped
+}
+
+// Discarded returns the matches of the last scan that were discarded
+// as false positives, along with the reason they were discarded.
+func (a *SecretFinder) Discarded() []*DiscardedFinding {
+	return a.discarded
+}
+
+// Exclude the paths matching the given gitignore patterns from
+// subsequent scans, on top of the server defaults and the ignore file
+// of the repository.
+func (a *SecretFinder) SetExcludes(patterns []string) {
+	a.excludes = patterns
 }
 
 func (a *SecretFinder) FindSecrets(basepath string) []*models.FindingsInfo {
-	walkDone := make(chan bool)
+	a.basepath = basepath
+	a.loadExcludes(basepath)
+	a.collect(func() {
+		a.scanFiles(func() {
+			if err := filepath.WalkDir(basepath, a.WalkDirHandler); err != nil {
+				log.Printf("Error traversing repository tree: %s", err.Error())
+			}
+		})
+	})
+	a.sortResults()
+	a.verifyFindings()
 
-	go func() {
-		if err := filepath.WalkDir(basepath, a.WalkDirHandler); err != nil {
-			log.Printf("Error traversing repository tree: %s", err.Error())
+	return a.findings
+}
+
+// Helper function to scan the files queued by the given producer using
+// a bounded pool of workers. The producer runs in the calling goroutine
+// and blocks while all of the workers are busy.
+func (a *SecretFinder) scanFiles(produce func()) {
+	workers := a.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	a.queue = make(chan string, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(queue <-chan string) {
+			defer wg.Done()
+			for path := range queue {
+				a.scanQueued(path)
+			}
+		}(a.queue)
+	}
+
+	produce()
+	close(a.queue)
+	a.queue = nil
+	wg.Wait()
+}
+
+// Helper function to scan a single file, recording it as skipped if
+// it cannot be read.
+func (a *SecretFinder) scanQueued(path string) {
+	if err := a.ScanFile(path); err != nil {
+		log.Printf("Error when scanning %v: %v", path, err.Error())
+		a.skip(path, skipUnreadable)
+	}
+}
+
+// Helper function to order the results of a scan as if the files had
+// been scanned one at a time, in the order of the directory walk. The
+// results of a single file are always reported in order by the worker
+// that scanned it.
+func (a *SecretFinder) sortResults() {
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		return walkOrderLess(a.findings[i].Location.Path, a.findings[j].Location.Path)
+	})
+	sort.SliceStable(a.skipped, func(i, j int) bool {
+		return walkOrderLess(a.skipped[i].Path, a.skipped[j].Path)
+	})
+}
+
+// Helper function to compare two paths by the order in which the
+// directory walk visits them, which compares the names of each level.
+func walkOrderLess(p, q string) bool {
+	ps, qs := strings.Split(p, "/"), strings.Split(q, "/")
+	for i := 0; i < len(ps) && i < len(qs); i++ {
+		if ps[i] != qs[i] {
+			return ps[i] < qs[i]
 		}
-		walkDone <- true
+	}
+	return len(ps) < len(qs)
+}
+
+// Helper function to run the given work in a separate goroutine while
+// collecting the findings it reports.
+func (a *SecretFinder) collect(work func()) {
+	done := make(chan bool)
+
+	go func() {
+		work()
+		done <- true
 	}()
 
 	for running := true; running; {
 		select {
 		case fi := <-a.reportCh:
-			fi.Location.Path = strings.TrimPrefix(fi.Location.Path, basepath)
 			a.findings = append(a.findings, fi)
-		case <-walkDone:
+		case sf := <-a.skipCh:
+			a.skipped = append(a.skipped, sf)
+		case df := <-a.discardCh:
+			if a.config.Filters.Debug {
+				log.Printf("Discarded %v match at %v:%v: %v", df.Finding.RuleId, df.Finding.Location.Path,
+					df.Finding.Location.Positions.Begin.Line, df.Reason)
+			}
+			a.discarded = append(a.discarded, df)
+		case pv := <-a.verifyCh:
+			a.verifications = append(a.verifications, pv)
+		case <-done:
 			running = false
 		}
 	}
-
-	return a.findings
 }
 
 func (a *SecretFinder) WalkDirHandler(path string, d fs.DirEntry, err error) error {
@@ -80,76 +207,354 @@ func (a *SecretFinder) WalkDirHandler(path string, d fs.DirEntry, err error) err
 			return filepath.SkipDir
 		}
 
+		// skip excluded subdirectories
+		if path != a.basepath && a.isExcluded(strings.TrimPrefix(path, a.basepath), true) {
+			return filepath.SkipDir
+		}
+
 		// don't need to do anything for other subdirectories
 		return nil
 	}
 
-	if errr := a.ScanFile(path); errr != nil {
-		log.Printf("Error when scanning %v: %v", path, errr.Error())
+	// submodules link to their repository with a .git file
+	if d.Name() == ".git" || a.isExcluded(strings.TrimPrefix(path, a.basepath), false) {
+		return nil
+	}
+
+	// links are not followed, as they can point outside of the tree
+	if d.Type()&fs.ModeSymlink != 0 {
+		return nil
+	}
+
+	// files are handed to the workers of the scan if there are any
+	if a.queue != nil {
+		a.queue <- path
+	} else {
+		a.scanQueued(path)
 	}
 
 	return nil
 }
 
+// ScanFile runs the analyzers over the file at the given path. Files
+// that are too large or look binary are skipped.
 func (a *SecretFinder) ScanFile(path string) error {
-	if file, err := os.Open(path); err != nil {
+	file, err := os.Open(path)
+	if err != nil {
 		return err
-	} else {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		scanner.Split(bufio.ScanLines)
-
-		// Regular expression attempts to match the keywords 'private_key' or 'public_key', followed
-		// by an optional ':', '=', or ':=', then followed by a token not starting with ',' or ';'.
-		//
-		// e.g. private_key := "lkasjdlkajsdlkajsdp"
-		re := regexp.MustCompile(`((?:private_key)|(?:public_key))['"]?\s*(?:(?::=)|(?:[:=])|(?:\s))\s*(?:([^;,:={}\s]+)|$)`)
-		prevLine := ""
-		prevLineCnt := 0
-		for lineCnt := 1; scanner.Scan(); lineCnt++ {
-			line := scanner.Text()
-			groups := re.FindAllStringSubmatch(prevLine+line, -1)
-			if len(groups) > 0 {
-				for _, group := range groups {
-					if group[2] == "" {
-						// prefix found, but token not found
-						// buffer it and see if the token appears later
-						if prevLine == "" {
-							prevLineCnt = lineCnt
-						}
-						prevLine = prevLine + line
-					} else {
-						// full match
-						matchType := group[1]
-
-						var fl models.FileLocation
-						if prevLine != "" {
-							fl.Begin = &models.LineLocation{Line: int32(prevLineCnt)}
-							fl.End = &models.LineLocation{Line: int32(lineCnt)}
-							prevLine = ""
-						} else {
-							fl.Begin = &models.LineLocation{Line: int32(lineCnt)}
-						}
-
-						a.reportCh <- &models.FindingsInfo{
-							Type_:  "sast",
-							RuleId: a.ruleDefs[matchType].Id,
-							Location: &models.FindingsLocation{
-								Path:      path,
-								Positions: &fl,
-							},
-							Metadata: &models.FindingsMetadata{
-								Description: a.ruleDefs[matchType].Description,
-								Severity:    a.ruleDefs[matchType].Severity,
-							},
-						}
-					}
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if kind := archiveKind(path); kind != "" {
+		return a.scanArchiveFile(path, kind, file, info.Size())
+	}
+
+	if max := a.config.Limits.MaxFileSize; max > 0 && info.Size() > max {
+		a.skip(path, skipTooLarge)
+		return nil
+	}
+
+	if err := a.scanReader(path, file); err != errBinaryContent {
+		return err
+	}
+
+	a.skip(path, skipBinary)
+	return nil
+}
+
+// Helper function to run all of the applicable analyzers over the
+// lines read from r. Findings are reported against the given path.
+// Returns errBinaryContent without scanning if r looks binary.
+func (a *SecretFinder) scanReader(path string, r io.Reader) error {
+	fs := a.newFileScan(path)
+	if len(fs.analyzers) == 0 {
+		return nil
+	}
+
+	br := bufio.NewReaderSize(r, sniffLength)
+	head, err := br.Peek(sniffLength)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+
+	if isBinary(head) {
+		return errBinaryContent
+	}
+
+	err = readLines(br, fs.scanLine)
+	fs.finish()
+
+	return err
+}
+
+// Helper function to record that the file at the given path was not
+// scanned.
+func (a *SecretFinder) skip(path, reason string) {
+	a.skipCh <- &models.SkippedFile{
+		Path:   strings.TrimPrefix(path, a.basepath),
+		Reason: reason,
+	}
+}
+
+// A candidate secret found by one of the analyzers.
+type match struct {
+	rule   *RuleDefinition
+	begin  position
+	end    position
+	secret string
+	// the text that matched, shown with the secret redacted
+	context string
+	// for values of structured files, the path of their key
+	keyPath string
+	// for secrets found in decoded text, the encoding and the encoded
+	// text that was matched
+	encoding string
+	encoded  string
+}
+
+// A position in the scanned file. The column starts at 1 and, like
+// the offset, counts bytes.
+type position struct {
+	line   int
+	column int
+	offset int64
+}
+
+// Helper function to return the position n bytes further on the same
+// line.
+func (p position) advance(n int) position {
+	return position{line: p.line, column: p.column + n, offset: p.offset + int64(n)}
+}
+
+// An analyzer inspects the lines of a single file in order and
+// reports the matches it finds through its fileScan.
+type lineAnalyzer interface {
+	scanLine(line string, num int)
+	finish()
+}
+
+// Per-file state kept while the lines of a file are scanned.
+type fileScan struct {
+	finder       *SecretFinder
+	path         string
+	commit       *models.CommitInfo
+	analyzers    []lineAnalyzer
+	suppressions map[int]map[string]bool
+	// matches reported when the end of the file is reached
+	matches []*match
+	// offset of the line being scanned, and index of the chunk of
+	// that line being scanned
+	lineOffset int64
+	chunkStart int
+}
+
+// Helper function to prepare the analyzers for the rules that apply
+// to the file at the given path.
+func (a *SecretFinder) newFileScan(path string) *fileScan {
+	fs := &fileScan{
+		finder:    a,
+		path:      path,
+		analyzers: make([]lineAnalyzer, 0),
+	}
+
+	relpath := strings.TrimPrefix(path, a.basepath)
+	rules := make(map[string][]*RuleDefinition)
+	for _, rd := range a.config.EnabledRules() {
+		if rd.AppliesTo(relpath) {
+			rules[rd.Analyzer] = append(rules[rd.Analyzer], rd)
+		}
+	}
+
+	if len(rules[analyzerRegex]) > 0 {
+		fs.analyzers = append(fs.analyzers, newRegexAnalyzer(fs, rules[analyzerRegex]))
+	}
+
+	plainKey, encryptedKey := firstRule(rules[analyzerPrivateKey]), firstRule(rules[analyzerEncryptedPrivateKey])
+	if plainKey != nil || encryptedKey != nil {
+		fs.analyzers = append(fs.analyzers, newPrivateKeyAnalyzer(fs, plainKey, encryptedKey))
+	}
+
+	if rd := firstRule(rules[analyzerEntropy]); rd != nil {
+		fs.analyzers = append(fs.analyzers, newEntropyAnalyzer(fs, rd, &a.config.Entropy))
+	}
+
+	if a.config.Decoding.IsEnabled() && (len(rules[analyzerRegex]) > 0 || plainKey != nil || encryptedKey != nil) {
+		fs.analyzers = append(fs.analyzers, newDecodingAnalyzer(fs, rules[analyzerRegex], plainKey, encryptedKey, &a.config.Decoding))
+	}
+
+	if format := structuredFormat(relpath); format != "" && len(rules[analyzerStructured]) > 0 {
+		fs.analyzers = append(fs.analyzers, newStructuredAnalyzer(fs, rules[analyzerStructured], format))
+	}
+
+	return fs
+}
+
+// Helper function to pass a line of the file, starting at the given
+// offset, to every analyzer.
+func (fs *fileScan) scanLine(line string, num int, offset int64) {
+	fs.noteSuppressions(line, num)
+
+	chunks := []string{line}
+	if max := fs.finder.config.Limits.MaxLineLength; max > 0 && len(line) > max {
+		chunks = splitLongLine(line, max)
+	}
+
+	fs.lineOffset, fs.chunkStart = offset, 0
+	for _, chunk := range chunks {
+		for _, an := range fs.analyzers {
+			an.scanLine(chunk, num)
+		}
+		fs.chunkStart += len(chunk)
+	}
+}
+
+// Helper function to return the position of the byte at index i of
+// the chunk of line num that is being scanned.
+func (fs *fileScan) position(num, i int) position {
+	col := fs.chunkStart + i
+	return position{line: num, column: col + 1, offset: fs.lineOffset + int64(col)}
+}
+
+// Helper function to notify every analyzer that the end of the file
+// has been reached, and send the findings of the file to the secret
+// finder.
+func (fs *fileScan) finish() {
+	fs.finishAnalyzers()
+
+	// the context of a finding can hold the secrets of other matches
+	secrets := make([]string, 0, 2*len(fs.matches))
+	for _, m := range fs.matches {
+		secrets = append(secrets, m.secret, m.encoded)
+	}
+
+	for _, m := range fs.matches {
+		if reason := fs.finder.config.Filters.discardReason(m); reason != "" {
+			fs.finder.discardCh <- &DiscardedFinding{Finding: fs.finding(m, secrets), Reason: reason}
+			continue
+		}
+		fi := fs.finding(m, secrets)
+		fs.finder.reportCh <- fi
+		if fs.finder.config.Verification.IsEnabled() && m.rule.Verifier != "" && !fi.Suppressed {
+			fs.finder.verifyCh <- &pendingVerification{finding: fi, verifier: m.rule.Verifier, secret: normalizeSecret(m.secret)}
+		}
+	}
+	fs.matches = nil
+}
+
+// Helper function to notify every analyzer that the end of the file
+// has been reached.
+func (fs *fileScan) finishAnalyzers() {
+	for _, an := range fs.analyzers {
+		an.finish()
+	}
+}
+
+// Helper function to convert the position for the findings.
+func (p position) location() *models.LineLocation {
+	return &models.LineLocation{
+		Line:   int32(p.line),
+		Column: int32(p.column),
+		Offset: p.offset,
+	}
+}
+
+// Helper function to return the first rule of the list, or nil.
+func firstRule(rules []*RuleDefinition) *RuleDefinition {
+	if len(rules) == 0 {
+		return nil
+	}
+	return rules[0]
+}
+
+// Helper function to record a match until the end of the file. Values
+// of structured files that were already matched by other rules only
+// add their key path to those matches. Decoded secrets replace the
+// matches of the same rule on their encoded text.
+func (fs *fileScan) report(m *match) {
+	if m.encoded != "" {
+		kept := fs.matches[:0]
+		for _, o := range fs.matches {
+			if o.rule != m.rule || !o.overlaps(m) {
+				kept = append(kept, o)
+			}
+		}
+		fs.matches = kept
+	}
+
+	if m.keyPath != "" {
+		found := false
+		for _, o := range fs.matches {
+			if o.overlaps(m) {
+				if o.keyPath == "" {
+					o.keyPath = m.keyPath
 				}
-			} else {
-				prevLine = ""
+				found = true
 			}
 		}
+		if found {
+			return
+		}
 	}
 
-	return nil
+	fs.matches = append(fs.matches, m)
+}
+
+// Helper function to check whether two matches share some text.
+func (m *match) overlaps(o *match) bool {
+	return m.begin.offset < o.end.offset && o.begin.offset < m.end.offset
+}
+
+// Helper function to convert a match into a finding, redacting the
+// given secrets of the file from its context.
+func (fs *fileScan) finding(m *match, secrets []string) *models.FindingsInfo {
+	fl := models.FileLocation{
+		Begin:  m.begin.location(),
+		End:    m.end.location(),
+		Length: m.end.offset - m.begin.offset,
+	}
+
+	path := strings.TrimPrefix(fs.path, fs.finder.basepath)
+	confidence, score := fs.finder.config.confidence(m, path)
+	fi := &models.FindingsInfo{
+		Type_:  "sast",
+		RuleId: m.rule.Id,
+		Location: &models.FindingsLocation{
+			Path:      path,
+			Positions: &fl,
+		},
+		Metadata: &models.FindingsMetadata{
+			Description: m.rule.Description,
+			Severity:    m.rule.Severity,
+			KeyPath:     m.keyPath,
+			Confidence:  confidence,
+			Score:       score,
+		},
+		Suppressed:  fs.isSuppressed(m),
+		Snippet:     redact(m.secret),
+		Context:     lineContext(m.context, m.secret, secrets),
+		Fingerprint: fingerprint(m.rule.Id, m.secret, path),
+		Encoding:    m.encoding,
+	}
+
+	// the encoded text is as sensitive as the secret
+	if m.encoded != "" {
+		fi.Context = lineContext(m.context, m.encoded, secrets)
+	}
+
+	if sm := fs.finder.submoduleOf(path); sm != nil {
+		s := *sm
+		fi.Submodule = &s
+	}
+
+	if fs.commit != nil {
+		c := *fs.commit
+		fi.Commit = &c
+	}
+
+	return fi
 }
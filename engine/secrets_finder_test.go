@@ -2,10 +2,16 @@ package engine_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
 )
 
 func TestFindSecretsHandlesInvalidDirectory(t *testing.T) {
@@ -46,3 +52,330 @@ func TestFindSecretsHandlesDirectorTree(t *testing.T) {
 		t.Logf("%v\n", string(b))
 	}
 }
+
+// Helper function to create a directory tree with the given file
+// contents, keyed by relative path.
+func makeSourceTree(t testing.TB, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Could not create directory: %v\n", err.Error())
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("Could not write file: %v\n", err.Error())
+		}
+	}
+	return dir
+}
+
+func TestFindSecretsUsesRuleMetadata(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"config.env": "private_key=abcdef\n# comment\npublic_key =\n  \"qwerty\"\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings. Got %v\n", len(findings))
+	}
+
+	fi := findings[0]
+	if fi.RuleId != "G001" || fi.Metadata.Description != "Hard-coded secret - private key" {
+		t.Errorf("Unexpected rule metadata: %v %v\n", fi.RuleId, fi.Metadata.Description)
+	}
+	if fi.Location.Path != "/config.env" || fi.Location.Positions.Begin.Line != 1 {
+		t.Errorf("Unexpected location: %v:%v\n", fi.Location.Path, fi.Location.Positions.Begin.Line)
+	}
+
+	fi = findings[1]
+	if fi.RuleId != "G002" {
+		t.Errorf("Expected rule G002. Got %v\n", fi.RuleId)
+	}
+	if fi.Location.Positions.Begin.Line != 3 || fi.Location.Positions.End == nil || fi.Location.Positions.End.Line != 4 {
+		t.Errorf("Expected finding to span lines 3-4.\n")
+	}
+}
+
+func TestFindSecretsHonoursRuleFilesAndEnabledFlag(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"a.go":  "private_key := \"abc\"\npublic_key := \"def\"\nsvc_tok_0123456789abcdef\n",
+		"b.txt": "private_key := \"abc\"\nsvc_tok_0123456789abcdef\n",
+	})
+
+	cfg, err := engine.ParseConfig([]byte(`
+rules:
+  - id: G002
+    enabled: false
+  - id: C001
+    description: Internal service token
+    severity: MEDIUM
+    regex: 'svc_tok_(?P<secret>[0-9a-f]{16})'
+    keywords: [svc_tok_]
+    files: ["*.go"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	counts := make(map[string]int)
+	for _, fi := range findings {
+		counts[fi.Location.Path+" "+fi.RuleId]++
+	}
+
+	expected := map[string]int{"/a.go G001": 1, "/a.go C001": 1, "/b.txt G001": 1}
+	if len(counts) != len(expected) {
+		t.Errorf("Expected findings %v. Got %v\n", expected, counts)
+	}
+	for k, v := range expected {
+		if counts[k] != v {
+			t.Errorf("Expected %v finding(s) for '%v'. Got %v\n", v, k, counts[k])
+		}
+	}
+}
+
+func TestFindSecretsBuiltinDetectors(t *testing.T) {
+	// Samples are assembled at runtime so that this file does not
+	// itself look like it contains credentials.
+	samples := map[string]string{
+		"G003": "aws_access_key_id = " + "AKIA" + "IOSFODNN7QWERTYU",
+		"G004": "aws_secret_access_key = \"" + strings.Repeat("wJalrXUtnFEMI/K7MDENG/bPxRfiCY", 1) + "Q1W2E3R4T5\"",
+		"G005": "token: " + "ghp_" + strings.Repeat("a1B2c3", 6),
+		"G006": "GITLAB_TOKEN=" + "glpat-" + strings.Repeat("x9", 10),
+		"G007": "slack = '" + "xoxb-" + "123456789012-" + strings.Repeat("Ab", 12) + "'",
+		"G008": "url: https://hooks.slack.com/services/" + "T0000/B0000/" + strings.Repeat("Xy7", 8),
+		"G009": "stripe.api_key = \"" + "sk_live_" + strings.Repeat("4eC39HqL", 3) + "\"",
+		"G010": "const key = \"" + "AIza" + strings.Repeat("SyD-", 8) + "abc\"",
+		"G011": "conn=DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=" + strings.Repeat("Zm9v", 21) + "ab==",
+		"G012": "auth: Bearer " + "eyJ" + "hbGciOiJIUzI1NiJ9." + "eyJ" + "zdWIiOiIxMjM0NTY3ODkwIn0." + "dozjgNryP4J3jVmNHl0w5N",
+		"G013": "db_password = \"hunter22\"",
+	}
+
+	files := make(map[string]string)
+	for id, line := range samples {
+		files[id+".txt"] = "first line\n" + line + "\nlast line\n"
+	}
+	dir := makeSourceTree(t, files)
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	found := make(map[string][]string)
+	for _, fi := range findings {
+		// random looking samples are also reported by the entropy rule
+		if fi.RuleId == "G016" {
+			continue
+		}
+		found[fi.Location.Path] = append(found[fi.Location.Path], fi.RuleId)
+		if fi.Location.Positions.Begin.Line != 2 {
+			t.Errorf("Expected %v finding on line 2. Got %v\n", fi.RuleId, fi.Location.Positions.Begin.Line)
+		}
+		if fi.Metadata.Description == "" || fi.Metadata.Severity == "" {
+			t.Errorf("Expected %v finding to carry rule metadata.\n", fi.RuleId)
+		}
+	}
+
+	for id := range samples {
+		rules := found["/"+id+".txt"]
+		if len(rules) != 1 || rules[0] != id {
+			t.Errorf("Expected only rule %v to match its sample. Got %v\n", id, rules)
+		}
+	}
+}
+
+func TestFindSecretsParallelScanIsOrdered(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("dir%02d/a.txt", i)] = "private_key=one\nhello\npublic_key=two\n"
+		files[fmt.Sprintf("dir%02d.txt", i)] = "private_key=three\n"
+	}
+	dir := makeSourceTree(t, files)
+
+	var sequential engine.SecretFinder
+	sequential.Initialize()
+	sequential.SetWorkers(1)
+	expected := sequential.FindSecrets(dir)
+
+	var parallel engine.SecretFinder
+	parallel.Initialize()
+	parallel.SetWorkers(8)
+	findings := parallel.FindSecrets(dir)
+
+	if len(findings) != len(expected) || len(findings) != 60 {
+		t.Fatalf("Expected 60 findings from both scans. Got %v and %v\n", len(expected), len(findings))
+	}
+
+	for i := range findings {
+		e, f := expected[i], findings[i]
+		if e.RuleId != f.RuleId || e.Location.Path != f.Location.Path || e.Location.Positions.Begin.Line != f.Location.Positions.Begin.Line {
+			t.Errorf("Expected finding %v to be %v at %v. Got %v at %v\n", i, e.RuleId, e.Location.Path, f.RuleId, f.Location.Path)
+		}
+	}
+}
+
+// Helper function to generate a tree of source files in which only a
+// few lines hold secrets, as in a typical repository.
+func makeBenchmarkTree(b *testing.B) string {
+	files := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		var sb strings.Builder
+		for j := 0; j < 500; j++ {
+			if j%100 == 0 {
+				fmt.Fprintf(&sb, "private_key = \"secret%04d\"\n", j)
+			} else {
+				fmt.Fprintf(&sb, "\tresult%d := compute(input, %d) // nothing to see here\n", j, j)
+			}
+		}
+		files[fmt.Sprintf("pkg%02d/file%03d.go", i%10, i)] = sb.String()
+	}
+	return makeSourceTree(b, files)
+}
+
+// Helper function to benchmark full scans of a generated tree using
+// the given number of workers.
+func benchmarkFindSecrets(b *testing.B, workers int) {
+	dir := makeBenchmarkTree(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var sf engine.SecretFinder
+		sf.Initialize()
+		sf.SetWorkers(workers)
+		sf.FindSecrets(dir)
+	}
+}
+
+func BenchmarkFindSecretsSequential(b *testing.B) {
+	benchmarkFindSecrets(b, 1)
+}
+
+func BenchmarkFindSecretsParallel(b *testing.B) {
+	benchmarkFindSecrets(b, runtime.NumCPU())
+}
+
+func TestFindSecretsRedactsAndFingerprintsFindings(t *testing.T) {
+	files := map[string]string{
+		"a.env":     "private_key = abcdefghijklmnop # test\n",
+		"c/a.env":   "private_key = abcdefghijklmnop # test\n",
+		"short.env": "private_key = abc\n",
+	}
+
+	scan := func() map[string]*models.FindingsInfo {
+		var sf engine.SecretFinder
+		sf.Initialize()
+
+		byPath := make(map[string]*models.FindingsInfo)
+		for _, fi := range sf.FindSecrets(makeSourceTree(t, files)) {
+			if fi.RuleId == "G001" {
+				byPath[fi.Location.Path] = fi
+			}
+		}
+		return byPath
+	}
+
+	byPath := scan()
+	if len(byPath) != 3 {
+		t.Fatalf("Expected a G001 finding in each of the 3 files. Got %v\n", len(byPath))
+	}
+
+	for path, fi := range byPath {
+		if strings.Contains(fi.Context, "abcdefghijklmnop") || strings.Contains(fi.Snippet, "abcdefghijklmnop") {
+			t.Errorf("Expected the secret to be redacted in %v. Got '%v' and '%v'\n", path, fi.Snippet, fi.Context)
+		}
+		if len(fi.Fingerprint) != 64 {
+			t.Errorf("Expected a SHA-256 fingerprint for %v. Got '%v'\n", path, fi.Fingerprint)
+		}
+	}
+
+	if fi := byPath["/a.env"]; fi.Snippet != "abcd********mnop" || fi.Context != "private_key = abcd********mnop # test" {
+		t.Errorf("Unexpected snippet '%v' and context '%v'\n", fi.Snippet, fi.Context)
+	}
+
+	if fi := byPath["/short.env"]; fi.Snippet != "***" {
+		t.Errorf("Expected short secrets to be fully masked. Got '%v'\n", fi.Snippet)
+	}
+
+	if byPath["/a.env"].Fingerprint == byPath["/c/a.env"].Fingerprint {
+		t.Errorf("Expected the fingerprint to depend on the path.\n")
+	}
+
+	// moving the secret within the file keeps its fingerprint
+	files["a.env"] = "# moved\n\nprivate_key = abcdefghijklmnop\n"
+	if moved := scan()["/a.env"]; moved == nil || moved.Fingerprint != byPath["/a.env"].Fingerprint {
+		t.Errorf("Expected the fingerprint to be stable when the secret moves.\n")
+	}
+}
+
+func TestFindSecretsRedactsOtherSecretsOfContext(t *testing.T) {
+	accent := strings.Repeat("\u00e9", 150)
+	dir := makeSourceTree(t, map[string]string{
+		"a.env": "private_key = abcdefghijklmnop public_key = qrstuvwxyz123456\n",
+		"b.env": accent + " private_key = abcdefghijklmnop " + accent + "\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 3 {
+		t.Fatalf("Expected 3 findings. Got %v\n", len(findings))
+	}
+
+	for _, fi := range findings {
+		if strings.Contains(fi.Context, "abcdefghijklmnop") || strings.Contains(fi.Context, "qrstuvwxyz123456") {
+			t.Errorf("Expected every secret to be redacted from the context of %v. Got '%v'\n", fi.RuleId, fi.Context)
+		}
+		if !utf8.ValidString(fi.Context) || !utf8.ValidString(fi.Snippet) {
+			t.Errorf("Expected the context to be cut between characters. Got '%v'\n", fi.Context)
+		}
+	}
+}
+
+func TestFindSecretsReportsColumnsAndOffsets(t *testing.T) {
+	long := strings.Repeat("x = 1, ", 20)
+	dir := makeSourceTree(t, map[string]string{
+		"a.env": "# first line\r\n" + long + "private_key=abcdef\n",
+		"b.env": "# comment\npublic_key =\n  \"qwerty\"\n",
+	})
+
+	cfg := engine.DefaultConfig()
+	cfg.Limits.MaxLineLength = 100
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings. Got %v\n", len(findings))
+	}
+
+	// the secret is found in the second chunk of the long line
+	pos := findings[0].Location.Positions
+	column := int32(len(long) + len("private_key=") + 1)
+	offset := int64(len("# first line\r\n")) + int64(column) - 1
+	if pos.Begin.Line != 2 || pos.Begin.Column != column || pos.Begin.Offset != offset {
+		t.Errorf("Expected secret to begin at 2:%v (offset %v). Got %v:%v (offset %v)\n", column, offset, pos.Begin.Line, pos.Begin.Column, pos.Begin.Offset)
+	}
+	if pos.End == nil || pos.End.Line != 2 || pos.End.Column != column+6 || pos.End.Offset != offset+6 {
+		t.Errorf("Expected secret to end at 2:%v.\n", column+6)
+	}
+	if pos.Length != 6 {
+		t.Errorf("Expected secret of 6 bytes. Got %v\n", pos.Length)
+	}
+
+	// matches spanning several lines begin with their prefix
+	pos = findings[1].Location.Positions
+	if pos.Begin.Line != 2 || pos.Begin.Column != 1 || pos.Begin.Offset != 10 {
+		t.Errorf("Expected match to begin at 2:1 (offset 10). Got %v:%v (offset %v)\n", pos.Begin.Line, pos.Begin.Column, pos.Begin.Offset)
+	}
+	if pos.End == nil || pos.End.Line != 3 || pos.End.Column != 11 || pos.End.Offset != 33 {
+		t.Errorf("Expected match to end at 3:11 (offset 33).\n")
+	}
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// How often the size of a checkout is measured while it grows.
+const sizeCheckInterval = 200 * time.Millisecond
+
+// Keeps what a checkout adds to its directories under a limit, since
+// the size of a repository is only known once it has been fetched.
+type sizeGuard struct {
+	dirs  []string
+	limit int64
+	// size of the directories before the checkout
+	base     int64
+	exceeded int32
+}
+
+// Helper function to create a guard for the directories, measuring
+// what they already hold.
+func newSizeGuard(dirs []string, limit int64) *sizeGuard {
+	g := &sizeGuard{dirs: dirs, limit: limit}
+	g.base = g.size()
+	return g
+}
+
+// Measure the directories until the context is done, cancelling it as
+// soon as they grow past the limit.
+func (g *sizeGuard) watch(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(sizeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if g.check() != nil {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// Measure the directories once. Returns an error if they grew by more
+// than the limit together.
+func (g *sizeGuard) check() error {
+	if added := g.size() - g.base; added > g.limit {
+		atomic.StoreInt32(&g.exceeded, 1)
+		return fmt.Errorf("added size of %v bytes exceeds the limit", added)
+	}
+	return nil
+}
+
+// Helper function to add up the size of the directories.
+func (g *sizeGuard) size() int64 {
+	var size int64
+	for _, dir := range g.dirs {
+		size += dirSize(dir)
+	}
+	return size
+}
+
+// Tell whether the directories were found to grow past the limit.
+func (g *sizeGuard) isExceeded() bool {
+	return atomic.LoadInt32(&g.exceeded) == 1
+}
+
+// Helper function to add up the size of the files under the
+// directory. Files that vanish while walking are not counted.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
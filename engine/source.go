@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of sources a repository can be scanned from.
+const (
+	SourceGit     = "git"
+	SourceArchive = "archive"
+	SourcePath    = "path"
+)
+
+// Source provides the files that a job scans.
+type Source interface {
+	// Fetch makes the files available on disk. Sources that copy the
+	// files put them in the given empty scratch directory.
+	Fetch(scratchDir string) (*Snapshot, error)
+	// Release frees what the source holds once the files have been
+	// scanned. It is called whether Fetch succeeded or not.
+	Release()
+}
+
+// Files fetched from a source.
+type Snapshot struct {
+	// directory holding the files to scan
+	Dir string
+	// what was checked out if the files come from a git repository,
+	// nil otherwise
+	Checkout *Checkout
+}
+
+// GitSource clones a repository, or checks it out from its mirror if
+// there is a mirror cache.
+type GitSource struct {
+	Url     string
+	Options *CheckoutOptions
+	Mirrors *MirrorCache
+}
+
+func (gs *GitSource) Fetch(scratchDir string) (*Snapshot, error) {
+	var co *Checkout
+	var err error
+	if gs.Mirrors != nil {
+		co, err = gs.Mirrors.Checkout(gs.Url, gs.Options, scratchDir)
+	} else {
+		co, err = CheckoutRepository(gs.Url, gs.Options, scratchDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Dir: scratchDir, Checkout: co}, nil
+}
+
+// The checkout borrows the objects of the mirror, which must stay in
+// the cache until the scan is done.
+func (gs *GitSource) Release() {
+	if gs.Mirrors != nil {
+		gs.Mirrors.Release(gs.Url)
+	}
+}
+
+// LocalSource scans a directory of the server in place. The directory
+// must be located under one of the allowed roots, once symbolic links
+// are resolved.
+type LocalSource struct {
+	Path  string
+	Roots []string
+}
+
+func (ls *LocalSource) Fetch(scratchDir string) (*Snapshot, error) {
+	dir, err := filepath.EvalSymlinks(ls.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open local path %v: %v", ls.Path, err.Error())
+	}
+
+	if !LocalPathAllowed(dir, ls.Roots) {
+		return nil, fmt.Errorf("local path %v is not allowed", ls.Path)
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("local path %v is not a directory", ls.Path)
+	}
+
+	return &Snapshot{Dir: dir}, nil
+}
+
+func (ls *LocalSource) Release() {}
+
+// LocalPathAllowed tells whether the given path is one of the roots or
+// is located under one of them. Paths are compared as given, so both
+// should be absolute and free of symbolic links.
+func LocalPathAllowed(path string, roots []string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+
+	path = filepath.Clean(path)
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		root = filepath.Clean(root)
+
+		prefix := root
+		if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+			prefix += string(filepath.Separator)
+		}
+		if path == root || strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,156 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// Helper function to write an archive to a file of its own.
+func writeArchive(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Could not write archive: %v\n", err.Error())
+	}
+	return path
+}
+
+func TestArchiveSourceExtractsArchives(t *testing.T) {
+	files := map[string]string{
+		"config/app.env": "private_key=abcdef\n",
+		"./README.md":    "# App\n",
+	}
+
+	for _, contents := range []string{makeZip(t, files), makeTarGz(t, files)} {
+		dir := t.TempDir()
+		snap, err := (&engine.ArchiveSource{Path: writeArchive(t, contents)}).Fetch(dir)
+		if err != nil {
+			t.Errorf("Extraction failed: %v\n", err.Error())
+			continue
+		}
+
+		if snap.Dir != dir || snap.Checkout != nil {
+			t.Errorf("Expected files in %v without a checkout. Got %v\n", dir, snap)
+		}
+
+		for name, expected := range map[string]string{"config/app.env": files["config/app.env"], "README.md": "# App\n"} {
+			if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(got) != expected {
+				t.Errorf("Expected %v to contain %q. Got %q\n", name, expected, got)
+			}
+		}
+	}
+}
+
+func TestArchiveSourceRejectsInvalidArchives(t *testing.T) {
+	tests := []struct {
+		contents string
+		maxSize  int64
+		message  string
+	}{
+		{"just some text\n", 0, "unsupported archive format"},
+		{makeZip(t, map[string]string{"../escape.env": "private_key=abcdef\n"}), 0, "invalid entry name"},
+		{makeTarGz(t, map[string]string{"/etc/escape.env": "private_key=abcdef\n"}), 0, "invalid entry name"},
+		{makeZip(t, map[string]string{"a.txt": strings.Repeat("a", 100)}), 50, "maximum extracted size of 50 bytes"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		_, err := (&engine.ArchiveSource{Path: writeArchive(t, tt.contents), MaxSize: tt.maxSize}).Fetch(dir)
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("Expected error containing %q. Got %v\n", tt.message, err)
+		}
+	}
+}
+
+func TestLocalSourceRequiresAllowedRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "drop"), 0755)
+
+	// a link inside the root leading out of it is not allowed either
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Could not create link: %v\n", err.Error())
+	}
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{root, true},
+		{filepath.Join(root, "drop"), true},
+		{filepath.Join(root, "drop", "..", "..", filepath.Base(outside)), false},
+		{outside, false},
+		{filepath.Join(root, "link"), false},
+		{filepath.Join(root, "missing"), false},
+	}
+
+	for _, tt := range tests {
+		_, err := (&engine.LocalSource{Path: tt.path, Roots: []string{root}}).Fetch(t.TempDir())
+		if tt.allowed && err != nil {
+			t.Errorf("Expected %v to be allowed. Got %v\n", tt.path, err.Error())
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("Expected %v to be refused, but error not returned.\n", tt.path)
+		}
+	}
+}
+
+func TestScannerWorksOnArchiveAndLocalPath(t *testing.T) {
+	files := map[string]string{"config/app.env": "private_key=abcdef\n"}
+	root := makeSourceTree(t, files)
+
+	// links are not followed out of the scanned tree
+	secret := makeSourceTree(t, map[string]string{"outside.env": "public_key=ghijkl\n"})
+	os.Symlink(filepath.Join(secret, "outside.env"), filepath.Join(root, "linked.env"))
+
+	var s engine.Scanner
+	s.Initialize(1, false)
+	s.AllowLocalPaths([]string{root})
+
+	jobs := []*engine.Job{
+		{
+			Id:      "A",
+			Repo:    &models.RepositoryInfo{Name: "upload", Source: engine.SourceArchive},
+			Archive: writeArchive(t, makeTarGz(t, files)),
+		},
+		{
+			Id:   "B",
+			Repo: &models.RepositoryInfo{Name: "local", Source: engine.SourcePath, Url: root},
+		},
+	}
+
+	for _, j := range jobs {
+		results := make(chan *engine.JobUpdate)
+		j.Result = results
+
+		s.StartScan(j)
+		r := waitForJobResult(t, results)
+
+		if r.Status != "SUCCESS" {
+			t.Fatalf("Expected job status to be SUCCESS. Got %v (%v)\n", r.Status, r.Reason)
+		}
+		if len(r.Findings) != 1 || r.Findings[0].Location.Path != "/config/app.env" {
+			t.Errorf("Expected one finding in /config/app.env. Got %v\n", r.Findings)
+		}
+		if r.Commit != "" || r.Ref != "" {
+			t.Errorf("Expected no commit for %v. Got %v at %v\n", j.Repo.Source, r.Ref, r.Commit)
+		}
+	}
+
+	// only the tree of a local path can be scanned
+	results := make(chan *engine.JobUpdate)
+	s.StartScan(&engine.Job{
+		Id:      "C",
+		Repo:    &models.RepositoryInfo{Name: "local", Source: engine.SourcePath, Url: root},
+		Request: &models.ScanRequest{Mode: "history"},
+		Result:  results,
+	})
+
+	if r := waitForJobResult(t, results); r.Status != "FAILURE" || !strings.Contains(r.Reason, "git repository") {
+		t.Errorf("Expected job to fail for lack of git repository. Got %v (%v)\n", r.Status, r.Reason)
+	}
+}
@@ -0,0 +1,442 @@
+package engine
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the configuration files parsed by the structured analyzer.
+const (
+	formatEnv        = "env"
+	formatProperties = "properties"
+	formatIni        = "ini"
+	formatYaml       = "yaml"
+	formatJson       = "json"
+)
+
+// Helper function to return the format of the configuration file with
+// the given path, or an empty string if it is not a known format.
+func structuredFormat(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	switch ext := filepath.Ext(name); {
+	case name == ".env" || strings.HasPrefix(name, ".env.") || ext == ".env":
+		return formatEnv
+	case ext == ".properties":
+		return formatProperties
+	case ext == ".ini" || ext == ".cfg":
+		return formatIni
+	case ext == ".yaml" || ext == ".yml":
+		return formatYaml
+	case ext == ".json":
+		return formatJson
+	}
+	return ""
+}
+
+// Analyzer that parses configuration files and reports the values of
+// keys whose name contains one of the keywords of its rules. The lines
+// are kept until the end of the file, when it is parsed.
+type structuredAnalyzer struct {
+	fs     *fileScan
+	rules  []*RuleDefinition
+	format string
+	lines  []bufferedLine
+}
+
+// A line of the file along with the position of its first byte.
+type bufferedLine struct {
+	text  string
+	start position
+}
+
+func newStructuredAnalyzer(fs *fileScan, rules []*RuleDefinition, format string) *structuredAnalyzer {
+	return &structuredAnalyzer{
+		fs:     fs,
+		rules:  rules,
+		format: format,
+	}
+}
+
+func (sa *structuredAnalyzer) scanLine(line string, num int) {
+	// chunks of long lines are joined again
+	if n := len(sa.lines); n > 0 && sa.lines[n-1].start.line == num {
+		sa.lines[n-1].text += line
+		return
+	}
+	sa.lines = append(sa.lines, bufferedLine{text: line, start: sa.fs.position(num, 0)})
+}
+
+func (sa *structuredAnalyzer) finish() {
+	switch sa.format {
+	case formatYaml:
+		sa.parseYaml()
+	case formatJson:
+		sa.parseJson()
+	default:
+		sa.parseKeyValues()
+	}
+	sa.lines = nil
+}
+
+// Helper function to report the value of the key at the given path if
+// the key is suspicious. The value spans from begin to end, and context
+// is the text shown for it.
+func (sa *structuredAnalyzer) value(keyPath, key, value string, begin, end position, context string) {
+	if isPlainValue(value) {
+		return
+	}
+
+	lower := strings.ToLower(key)
+	for _, rd := range sa.rules {
+		if !rd.hasKeywordLower(lower) {
+			continue
+		}
+
+		sa.fs.report(&match{
+			rule:    rd,
+			begin:   begin,
+			end:     end,
+			secret:  value,
+			context: context,
+			keyPath: keyPath,
+		})
+	}
+}
+
+// Helper function to check whether a value is empty, a number or a
+// boolean, none of which can be a secret.
+func isPlainValue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// Helper function to join the key of a mapping to the path of its
+// parent.
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Helper function to parse the lines of .env, properties and INI files,
+// where each line holds a key and its value. INI keys are prefixed with
+// the name of their section.
+func (sa *structuredAnalyzer) parseKeyValues() {
+	comments, separators := "#", "="
+	switch sa.format {
+	case formatProperties:
+		comments, separators = "#!", "=:"
+	case formatIni:
+		comments, separators = "#;", "=:"
+	}
+
+	section := ""
+	for _, bl := range sa.lines {
+		text := strings.TrimSpace(bl.text)
+		if text == "" || strings.ContainsRune(comments, rune(text[0])) {
+			continue
+		}
+
+		if sa.format == formatIni && strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+
+		sep := strings.IndexAny(bl.text, separators)
+		if sep < 0 {
+			continue
+		}
+
+		key := strings.TrimSpace(bl.text[:sep])
+		if sa.format == formatEnv {
+			key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		}
+		if key == "" {
+			continue
+		}
+
+		from, to := valueBounds(bl.text, sep+1, sa.format == formatEnv)
+		sa.value(joinKeyPath(section, key), key, bl.text[from:to],
+			bl.start.advance(from), bl.start.advance(to), bl.text)
+	}
+}
+
+// Helper function to return the bounds of the value that starts after
+// the separator of a line, without surrounding whitespace and quotes.
+// Unquoted values may end with a comment.
+func valueBounds(line string, from int, comments bool) (int, int) {
+	to := len(line)
+	for from < to && (line[from] == ' ' || line[from] == '\t') {
+		from++
+	}
+
+	if from < to && (line[from] == '"' || line[from] == '\'') {
+		if end := strings.IndexByte(line[from+1:], line[from]); end >= 0 {
+			return from + 1, from + 1 + end
+		}
+	}
+
+	if comments {
+		if i := strings.Index(line[from:], " #"); i >= 0 {
+			to = from + i
+		}
+	}
+	for to > from && (line[to-1] == ' ' || line[to-1] == '\t') {
+		to--
+	}
+
+	return from, to
+}
+
+// Helper function to return the text of the buffered lines, along with
+// the offset of each line within it.
+func (sa *structuredAnalyzer) joinLines() (string, []int) {
+	var sb strings.Builder
+	starts := make([]int, len(sa.lines))
+	for i, bl := range sa.lines {
+		starts[i] = sb.Len()
+		sb.WriteString(bl.text)
+		sb.WriteByte('\n')
+	}
+	return sb.String(), starts
+}
+
+// Helper function to parse a YAML file. Documents that cannot be
+// parsed are ignored, as the line based rules still apply to them.
+func (sa *structuredAnalyzer) parseYaml() {
+	text, _ := sa.joinLines()
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			return
+		}
+		sa.walkYaml(&doc, nil, "", "")
+	}
+}
+
+// Helper function to walk the nodes of a YAML document. key is the
+// name of the key holding node, and keyNode the node of that key.
+func (sa *structuredAnalyzer) walkYaml(node, keyNode *yaml.Node, path, key string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			sa.walkYaml(n, nil, path, key)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k := node.Content[i]
+			sa.walkYaml(node.Content[i+1], k, joinKeyPath(path, k.Value), k.Value)
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			sa.walkYaml(n, keyNode, path+"["+strconv.Itoa(i)+"]", key)
+		}
+	case yaml.ScalarNode:
+		if keyNode == nil || node.Tag != "!!str" || node.Line < 1 || node.Line > len(sa.lines) {
+			return
+		}
+
+		begin, end := sa.locateValue(node.Line-1, sa.yamlColumn(node), node.Value)
+
+		// values spanning several lines are shown by their key
+		context := sa.lineText(begin)
+		if begin.line != end.line && keyNode.Line >= 1 && keyNode.Line <= len(sa.lines) {
+			context = sa.lines[keyNode.Line-1].text
+		}
+		sa.value(path, key, node.Value, begin, end, context)
+	}
+}
+
+// Helper function to convert the column of a YAML node, which counts
+// characters from 1, into a byte index within its line.
+func (sa *structuredAnalyzer) yamlColumn(node *yaml.Node) int {
+	line := sa.lines[node.Line-1].text
+	i := 0
+	for col := 1; col < node.Column && i < len(line); col++ {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	return i
+}
+
+// Helper function to find the value of a YAML node in the lines of the
+// file, starting at byte col of the line with index li. Returns the
+// positions of the first and past the last character of the value.
+// Values that cannot be found, such as those using escape sequences,
+// span the rest of the line of the node.
+func (sa *structuredAnalyzer) locateValue(li, col int, value string) (position, position) {
+	parts := make([]string, 0)
+	for _, part := range strings.Split(value, "\n") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	line := sa.lines[li]
+	if col > len(line.text) {
+		col = len(line.text)
+	}
+	if len(parts) == 0 {
+		return line.start.advance(col), line.start.advance(len(line.text))
+	}
+
+	// block scalars begin on the line following their indicator, and
+	// folded values may have been joined
+	first := parts[0]
+	if i := strings.IndexAny(first, " \t"); i > 0 && len(parts) == 1 {
+		first = first[:i]
+	}
+	bi, bcol, ok := sa.find(li, col, first, len(parts)+1)
+	if !ok {
+		return line.start.advance(col), line.start.advance(len(line.text))
+	}
+
+	last := parts[len(parts)-1]
+	if j := strings.LastIndexAny(last, " \t"); j >= 0 {
+		last = last[j+1:]
+	}
+	ei, ecol, ok := sa.find(bi, bcol, last, len(parts)+1)
+	if !ok {
+		ei, ecol, last = bi, bcol, first
+	}
+
+	return sa.lines[bi].start.advance(bcol), sa.lines[ei].start.advance(ecol + len(last))
+}
+
+// Helper function to return the text of the line holding the given
+// position.
+func (sa *structuredAnalyzer) lineText(p position) string {
+	return sa.lines[p.line-sa.lines[0].start.line].text
+}
+
+// Helper function to search for text in at most n lines, starting at
+// byte col of the line with index li.
+func (sa *structuredAnalyzer) find(li, col int, text string, n int) (int, int, bool) {
+	for i := li; i < len(sa.lines) && i < li+n; i++ {
+		line := sa.lines[i].text
+		from := 0
+		if i == li {
+			from = col
+		}
+		if from > len(line) {
+			continue
+		}
+		if j := strings.Index(line[from:], text); j >= 0 {
+			return i, from + j, true
+		}
+	}
+	return 0, 0, false
+}
+
+// A JSON object or array that is being parsed.
+type jsonFrame struct {
+	path   string
+	object bool
+	// for objects, the last key read and whether a key comes next;
+	// for arrays, the key holding the array and the index of the
+	// next element
+	key     string
+	wantKey bool
+	index   int
+}
+
+// Helper function to parse a JSON file, tracking the path of each value.
+// Files that cannot be parsed are ignored, as the line based rules still
+// apply to them.
+func (sa *structuredAnalyzer) parseJson() {
+	text, starts := sa.joinLines()
+	dec := json.NewDecoder(strings.NewReader(text))
+
+	var stack []*jsonFrame
+	var prev int64
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+
+		// tokens start after the whitespace and separators following
+		// the previous one
+		end := int(dec.InputOffset())
+		start := int(prev)
+		for start < end && strings.IndexByte(" \t\r\n:,", text[start]) >= 0 {
+			start++
+		}
+		prev = int64(end)
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			if v == '{' || v == '[' {
+				path, key := jsonChild(top)
+				stack = append(stack, &jsonFrame{path: path, object: v == '{', key: key, wantKey: true})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				jsonNext(stack[len(stack)-1])
+			}
+		case string:
+			if top != nil && top.object && top.wantKey {
+				top.key, top.wantKey = v, false
+				continue
+			}
+
+			if path, key := jsonChild(top); key != "" && end-start >= 2 {
+				begin, stop := sa.offsetPosition(starts, start+1), sa.offsetPosition(starts, end-1)
+				sa.value(path, key, v, begin, stop, sa.lineText(begin))
+			}
+			jsonNext(top)
+		default:
+			jsonNext(top)
+		}
+	}
+}
+
+// Helper function to return the path of the next value read in the
+// given object or array, and the name of the key holding it.
+func jsonChild(top *jsonFrame) (string, string) {
+	switch {
+	case top == nil:
+		return "", ""
+	case top.object:
+		return joinKeyPath(top.path, top.key), top.key
+	default:
+		return top.path + "[" + strconv.Itoa(top.index) + "]", top.key
+	}
+}
+
+// Helper function to move on to the next entry of the given object or
+// array once a value has been read.
+func jsonNext(top *jsonFrame) {
+	switch {
+	case top == nil:
+	case top.object:
+		top.wantKey = true
+	default:
+		top.index++
+	}
+}
+
+// Helper function to convert an offset within the joined lines into a
+// position in the file.
+func (sa *structuredAnalyzer) offsetPosition(starts []int, offset int) position {
+	i := sort.SearchInts(starts, offset+1) - 1
+	return sa.lines[i].start.advance(offset - starts[i])
+}
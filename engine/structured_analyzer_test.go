@@ -0,0 +1,84 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+)
+
+func TestFindSecretsInStructuredFiles(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"config.yaml":    "database:\n  prod:\n    user: admin\n    password: hunter22\n    timeout: 30\nsigning:\n  private_key: |\n    line-one-of-key\n    line-two-of-key\n",
+		"app.json":       "{\n  \"name\": \"app\",\n  \"credentials\": {\"user\": \"bob\", \"secret\": \"s3cr3t-value\"},\n  \"tokens\": [\"tok-one\", 42]\n}\n",
+		"app.properties": "# settings\ndb.url=jdbc:postgresql://localhost/app\ndb.password = changeit!\n",
+		"settings.ini":   "[smtp]\nhost = mail\napi_key = \"k3y-value\"\n",
+		".env":           "export API_TOKEN=abc123xyz # deploy token\nDEBUG=true\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	type location struct {
+		path    string
+		line    int32
+		column  int32
+		endLine int32
+	}
+	expected := map[string]location{
+		"database.prod.password": {"/config.yaml", 4, 15, 4},
+		"signing.private_key":    {"/config.yaml", 8, 5, 9},
+		"credentials.secret":     {"/app.json", 3, 45, 3},
+		"tokens[0]":              {"/app.json", 4, 15, 4},
+		"db.password":            {"/app.properties", 3, 15, 3},
+		"smtp.api_key":           {"/settings.ini", 3, 12, 3},
+		"API_TOKEN":              {"/.env", 1, 18, 1},
+	}
+
+	found := make(map[string]bool)
+	for _, fi := range findings {
+		keyPath := fi.Metadata.KeyPath
+		exp, ok := expected[keyPath]
+		if !ok {
+			if keyPath != "" || fi.RuleId == "G017" {
+				t.Errorf("Unexpected %v finding for key '%v' in %v\n", fi.RuleId, keyPath, fi.Location.Path)
+			}
+			continue
+		}
+		found[keyPath] = true
+
+		pos := fi.Location.Positions
+		if fi.Location.Path != exp.path || pos.Begin.Line != exp.line || pos.Begin.Column != exp.column || pos.End.Line != exp.endLine {
+			t.Errorf("Expected %v at %v:%v:%v-%v. Got %v:%v:%v-%v\n", keyPath, exp.path, exp.line, exp.column, exp.endLine,
+				fi.Location.Path, pos.Begin.Line, pos.Begin.Column, pos.End.Line)
+		}
+	}
+
+	for keyPath := range expected {
+		if !found[keyPath] {
+			t.Errorf("Expected a finding for key %v\n", keyPath)
+		}
+	}
+}
+
+func TestFindSecretsStructuredValuesAreNotReportedTwice(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"config.yml": "service:\n  password: hunter22\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	// the value is matched by the password rule, which gets the key path
+	if len(findings) != 1 || findings[0].RuleId != "G013" || findings[0].Metadata.KeyPath != "service.password" {
+		t.Fatalf("Expected a single G013 finding for service.password. Got %v\n", len(findings))
+	}
+}
+
+func TestStructuredRulesRequireKeywords(t *testing.T) {
+	_, err := engine.ParseConfig([]byte("rules:\n  - id: G100\n    description: Config value\n    severity: LOW\n    analyzer: structured\n"))
+	if err == nil {
+		t.Errorf("Expected structured rules without keywords to be rejected.\n")
+	}
+}
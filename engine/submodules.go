@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5"
+)
+
+// Deepest level of nested submodules that can be cloned.
+const MaxSubmoduleDepth = int(git.DefaultSubmoduleRecursionDepth)
+
+// ListSubmodules returns the submodules checked out in the repository
+// at the given path, including nested submodules, along with the
+// commit checked out for each of them. Submodules that were not
+// initialized are left out. Returns nil and an error if the repository
+// cannot be read.
+func ListSubmodules(repoPath string) ([]*models.SubmoduleInfo, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repository: %v", err.Error())
+	}
+
+	subs := make([]*models.SubmoduleInfo, 0)
+	if err := listSubmodules(repo, "/", &subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// Helper function to append the initialized submodules of the
+// repository checked out at the given path to the list.
+func listSubmodules(repo *git.Repository, prefix string, subs *[]*models.SubmoduleInfo) error {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("cannot open worktree: %v", err.Error())
+	}
+
+	submodules, err := wt.Submodules()
+	if err != nil {
+		return fmt.Errorf("cannot read submodules: %v", err.Error())
+	}
+
+	for _, sm := range submodules {
+		status, err := sm.Status()
+		if err != nil || status.Current.IsZero() {
+			continue
+		}
+
+		cfg := sm.Config()
+		info := &models.SubmoduleInfo{
+			Path: path.Join(prefix, cfg.Path),
+			Url:  cfg.URL,
+			Sha:  status.Current.String(),
+		}
+		*subs = append(*subs, info)
+
+		if r, err := sm.Repository(); err == nil {
+			if err := listSubmodules(r, info.Path, subs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Set the submodules checked out in the repository scanned next. The
+// findings of files in a submodule are annotated with the submodule.
+func (a *SecretFinder) SetSubmodules(subs []*models.SubmoduleInfo) {
+	// nested submodules are matched before their parents
+	a.submodules = append([]*models.SubmoduleInfo{}, subs...)
+	sort.SliceStable(a.submodules, func(i, j int) bool {
+		return len(a.submodules[i].Path) > len(a.submodules[j].Path)
+	})
+}
+
+// Helper function to return the innermost submodule holding the file
+// at the given path, relative to the root of the scanned tree, or nil
+// if the file is not part of a submodule.
+func (a *SecretFinder) submoduleOf(relpath string) *models.SubmoduleInfo {
+	for _, sm := range a.submodules {
+		if strings.HasPrefix(relpath, sm.Path+"/") {
+			return sm
+		}
+	}
+	return nil
+}
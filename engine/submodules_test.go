@@ -0,0 +1,135 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Helper function to create a repository holding the library repository
+// as a submodule at third_party/lib, along with a file of its own. Returns
+// the path of the repository and the commit of the library.
+func makeSuperRepo(t *testing.T, libFiles map[string]string) (string, string) {
+	lib, libHashes := makeGitRepo(t, []map[string]string{libFiles})
+	return makeSuperRepoWithUrl(t, lib, libHashes[0]), libHashes[0]
+}
+
+// Helper function to create a repository holding the given commit of the
+// repository at libUrl as a submodule at third_party/lib.
+func makeSuperRepoWithUrl(t *testing.T, libUrl, libHash string) string {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Could not initialize repository: %v\n", err.Error())
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Could not open worktree: %v\n", err.Error())
+	}
+
+	modules := "[submodule \"lib\"]\n\tpath = third_party/lib\n\turl = " + libUrl + "\n"
+	files := map[string]string{".gitmodules": modules, "main.go": "package main\n"}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("Could not write %v: %v\n", name, err.Error())
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatalf("Could not add %v: %v\n", name, err.Error())
+		}
+	}
+
+	// the submodule is recorded in the index as a link to its commit
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatalf("Could not read index: %v\n", err.Error())
+	}
+	e := idx.Add("third_party/lib")
+	e.Mode = filemode.Submodule
+	e.Hash = plumbing.NewHash(libHash)
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		t.Fatalf("Could not write index: %v\n", err.Error())
+	}
+
+	_, err = wt.Commit("add submodule", &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  "Dev Eloper",
+			Email: "dev@example.com",
+			When:  time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not commit: %v\n", err.Error())
+	}
+
+	return dir
+}
+
+func TestFindSecretsScansSubmodules(t *testing.T) {
+	super, libCommit := makeSuperRepo(t, map[string]string{"config.env": "name=lib\nprivate_key=k8Jd93hsQ\n"})
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if err := engine.CloneRepositoryWithSubmodules(super, "master", dir, 1); err != nil {
+		t.Fatalf("Clone repository failed: %v\n", err.Error())
+	}
+
+	subs, err := engine.ListSubmodules(dir)
+	if err != nil {
+		t.Fatalf("Failed to list submodules: %v\n", err.Error())
+	}
+	if len(subs) != 1 || subs[0].Path != "/third_party/lib" || subs[0].Sha != libCommit {
+		t.Fatalf("Expected the third_party/lib submodule at %v. Got %v\n", libCommit, subs)
+	}
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	sf.SetSubmodules(subs)
+	findings := sf.FindSecrets(dir)
+
+	// the path of the library in .gitmodules looks random
+	fi := findingAt(findings, "/third_party/lib/config.env")
+	if fi == nil {
+		t.Fatalf("Expected a finding in the submodule, with the submodule path. Got %v\n", len(findings))
+	}
+	if fi.Submodule == nil || fi.Submodule.Sha != libCommit {
+		t.Errorf("Expected the finding to record the submodule commit. Got %v\n", fi.Submodule)
+	}
+
+	if err := engine.BlameFindings(dir, []*models.FindingsInfo{fi}); err != nil {
+		t.Fatalf("Failed to blame findings: %v\n", err.Error())
+	}
+	if fi.Commit == nil || fi.Commit.Sha != libCommit {
+		t.Errorf("Expected the finding to be blamed in the submodule. Got %v\n", fi.Commit)
+	}
+}
+
+func TestCloneRepositoryLeavesSubmodulesOut(t *testing.T) {
+	super, _ := makeSuperRepo(t, map[string]string{"config.env": "private_key=k8Jd93hsQ\n"})
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if err := engine.CloneRepository(super, "master", dir); err != nil {
+		t.Fatalf("Clone repository failed: %v\n", err.Error())
+	}
+
+	if subs, err := engine.ListSubmodules(dir); err != nil || len(subs) != 0 {
+		t.Errorf("Expected no submodule to be checked out. Got %v\n", subs)
+	}
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	if fi := findingAt(sf.FindSecrets(dir), "/third_party/lib/config.env"); fi != nil {
+		t.Errorf("Expected the submodule not to be scanned. Got %v\n", fi)
+	}
+
+	if err := engine.CloneRepositoryWithSubmodules(super, "master", t.TempDir(), engine.MaxSubmoduleDepth+1); err == nil {
+		t.Errorf("Expected an error for a submodule depth beyond the limit\n")
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matches inline suppression comments, such as
+//
+//	private_key = "..." // reposcanner:ignore G001
+//	# reposcanner:ignore-next-line
+//
+// The directive may be followed by the ids of the rules to suppress.
+// Without ids, every rule is suppressed.
+var suppressionDirective = regexp.MustCompile(`reposcanner:ignore(-next-line)?\b([\s,\w\-]*)`)
+
+// Helper function to record the suppression directives found on the
+// given line. Must be called before the analyzers see the line.
+func (fs *fileScan) noteSuppressions(line string, num int) {
+	if !strings.Contains(line, "reposcanner:ignore") {
+		return
+	}
+
+	for _, m := range suppressionDirective.FindAllStringSubmatch(line, -1) {
+		target := num
+		if m[1] != "" {
+			target = num + 1
+		}
+
+		fs.suppress(target, fs.suppressedRules(m[2]))
+	}
+}
+
+// Helper function to parse the list of rule ids following a directive.
+// Parsing stops at the first word that is not a rule id, so that the
+// directive can be followed by a justification. Returns nil if no rule
+// id is given.
+func (fs *fileScan) suppressedRules(text string) map[string]bool {
+	var ids map[string]bool
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		if fs.finder.config.Rule(word) == nil {
+			break
+		}
+		if ids == nil {
+			ids = make(map[string]bool)
+		}
+		ids[word] = true
+	}
+
+	return ids
+}
+
+// Helper function to add the rules to those suppressed on the given
+// line. A nil set stands for every rule.
+func (fs *fileScan) suppress(num int, ids map[string]bool) {
+	if fs.suppressions == nil {
+		fs.suppressions = make(map[int]map[string]bool)
+	}
+
+	existing, ok := fs.suppressions[num]
+	switch {
+	case !ok:
+		fs.suppressions[num] = ids
+	case existing == nil:
+		// every rule is already suppressed
+	case ids == nil:
+		fs.suppressions[num] = nil
+	default:
+		for id := range ids {
+			existing[id] = true
+		}
+	}
+}
+
+// Helper function to check whether a match is covered by a suppression
+// directive. Matches spanning several lines are suppressed from their
+// first line.
+func (fs *fileScan) isSuppressed(m *match) bool {
+	ids, ok := fs.suppressions[m.begin.line]
+	if !ok {
+		return false
+	}
+
+	return ids == nil || ids[m.rule.Id]
+}
@@ -0,0 +1,63 @@
+package engine_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/engine"
+)
+
+func TestFindSecretsSuppressionComments(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"a.go": "private_key=abc // reposcanner:ignore G001\n",
+		"b.go": "private_key=abc // reposcanner:ignore G002\n",
+		"c.py": "# reposcanner:ignore-next-line\npublic_key=xyz\npublic_key=uvw\n",
+		"d.py": "public_key=xyz # reposcanner:ignore G001, G002 test fixture\n",
+		"e.sh": "private_key=abc # reposcanner:ignore fixture from the docs\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	expected := map[string]bool{
+		"/a.go:1": true,
+		"/b.go:1": false,
+		"/c.py:2": true,
+		"/c.py:3": false,
+		"/d.py:1": true,
+		"/e.sh:1": true,
+	}
+
+	if len(findings) != len(expected) {
+		t.Fatalf("Expected %v findings. Got %v\n", len(expected), len(findings))
+	}
+
+	for _, fi := range findings {
+		key := fmt.Sprintf("%v:%v", fi.Location.Path, fi.Location.Positions.Begin.Line)
+		suppressed, ok := expected[key]
+		if !ok {
+			t.Errorf("Unexpected finding at %v\n", key)
+		} else if fi.Suppressed != suppressed {
+			t.Errorf("Expected suppressed to be %v at %v. Got %v\n", suppressed, key, fi.Suppressed)
+		}
+	}
+}
+
+func TestFindSecretsSuppressesPrivateKeyBlock(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"key.pem": "# reposcanner:ignore-next-line G014\n" + makeRSAKey(t, false),
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding. Got %v\n", len(findings))
+	}
+
+	if !findings[0].Suppressed || findings[0].RuleId != "G014" {
+		t.Errorf("Expected a suppressed G014 finding. Got %v suppressed=%v\n", findings[0].RuleId, findings[0].Suppressed)
+	}
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// Statuses of the verification of a finding.
+const (
+	VerificationVerified = "verified"
+	VerificationInvalid  = "invalid"
+	VerificationUnknown  = "unknown"
+	VerificationError    = "error"
+)
+
+// Verifier checks whether a secret is live by asking the service that
+// issued it. Verify returns one of the verification statuses, and an
+// error along with VerificationError when the service could not be
+// asked. Verifiers are shared between concurrent scans.
+type Verifier interface {
+	Verify(ctx context.Context, secret string) (string, error)
+}
+
+// Function creating a verifier that sends its requests to the given
+// base URL using the given client.
+type VerifierFactory func(baseUrl string, client *http.Client) Verifier
+
+var verifierFactories = map[string]VerifierFactory{
+	"github": newGithubVerifier,
+	"gitlab": newGitlabVerifier,
+	"slack":  newSlackVerifier,
+	"stripe": newStripeVerifier,
+}
+
+// RegisterVerifier makes a verifier available to the rules under the
+// given name. It must be called before the rule file is loaded, such as
+// from an init function.
+func RegisterVerifier(name string, factory VerifierFactory) {
+	verifierFactories[name] = factory
+}
+
+// A verifier along with the limiter of its request rate.
+type limitedVerifier struct {
+	verifier Verifier
+	limiter  *rateLimiter
+}
+
+// Limiter spacing the requests sent to a service evenly.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Helper function to wait for the next request slot, or until the
+// context is done.
+func (rl *rateLimiter) wait(ctx context.Context) error {
+	rl.mu.Lock()
+	at := rl.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	rl.next = at.Add(rl.interval)
+	rl.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Helper function to validate the verification settings and create
+// the verifiers they configure.
+func (vs *VerificationSettings) compile() error {
+	if vs.Timeout < 1 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if vs.RateLimit <= 0 {
+		return fmt.Errorf("rateLimit must be positive")
+	}
+
+	client := &http.Client{Timeout: time.Duration(vs.Timeout) * time.Second}
+	interval := time.Duration(float64(time.Second) / vs.RateLimit)
+
+	vs.verifiers = make(map[string]*limitedVerifier)
+	for name, settings := range vs.Verifiers {
+		factory, ok := verifierFactories[name]
+		if !ok {
+			return fmt.Errorf("unknown verifier '%v'", name)
+		}
+		if settings == nil || settings.BaseUrl == "" {
+			return fmt.Errorf("verifier %v: missing baseUrl", name)
+		}
+
+		vs.verifiers[name] = &limitedVerifier{
+			verifier: factory(strings.TrimRight(settings.BaseUrl, "/"), client),
+			limiter:  &rateLimiter{interval: interval},
+		}
+	}
+
+	return nil
+}
+
+// Helper function to verify the secret with the named verifier, within
+// its rate limit and the timeout.
+func (vs *VerificationSettings) verify(name, secret string) (string, error) {
+	lv, ok := vs.verifiers[name]
+	if !ok {
+		return VerificationUnknown, nil
+	}
+
+	if err := lv.limiter.wait(context.Background()); err != nil {
+		return VerificationError, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(vs.Timeout)*time.Second)
+	defer cancel()
+
+	status, err := lv.verifier.Verify(ctx, secret)
+	if err != nil {
+		return VerificationError, err
+	}
+	return status, nil
+}
+
+// A secret found by a rule with a verifier, waiting to be verified
+// once the scan completes.
+type pendingVerification struct {
+	finding  *models.FindingsInfo
+	verifier string
+	secret   string
+}
+
+// Helper function to verify the secrets of the findings of the last
+// scan, in the order they were found. The same secret is only verified
+// once. Verified findings are of high confidence.
+func (a *SecretFinder) verifyFindings() {
+	if len(a.verifications) == 0 {
+		return
+	}
+
+	results := make(map[string]string)
+	for _, pv := range a.verifications {
+		fi := pv.finding
+		key := pv.verifier + "\x00" + pv.secret
+		status, ok := results[key]
+		if !ok {
+			var err error
+			status, err = a.config.Verification.verify(pv.verifier, pv.secret)
+			if err != nil {
+				log.Printf("Cannot verify %v finding at %v: %v", fi.RuleId, fi.Location.Path, err.Error())
+			}
+			results[key] = status
+		}
+
+		fi.Verification = status
+		if status == VerificationVerified && fi.Metadata != nil {
+			fi.Metadata.Confidence, fi.Metadata.Score = "HIGH", 100
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package engine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// GitHub tokens accepted, rejected and unknown to the stand-in API.
+const (
+	liveGithubToken    = "ghp_" + "Qm7xT2vLp9Rk4Wn8Zc3Hb6Yd1Jf5Gs0Ae7Uo"
+	revokedGithubToken = "ghp_" + "Lw4Kt8Nq2Xv6Bm9Pj3Rc7Hd5Zf1Gy0Ts8EiQ"
+	flakyGithubToken   = "ghp_" + "Vz5Mr1Yc8Kq3Tn7Xb2Wd6Lh9Pf4Gj0Rs5AoK"
+)
+
+func newGithubStandIn(t *testing.T, requests *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.URL.Path != "/user" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Header.Get("Authorization") {
+		case "token " + liveGithubToken:
+			w.Write([]byte(`{"login": "octocat"}`))
+		case "token " + revokedGithubToken:
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func githubFinding(findings []*models.FindingsInfo, path string) *models.FindingsInfo {
+	for _, fi := range findings {
+		if fi.Location.Path == path && fi.RuleId == "G005" {
+			return fi
+		}
+	}
+	return nil
+}
+
+func verificationConfig(t *testing.T, baseUrl string, extra string) *engine.Config {
+	cfg, err := engine.ParseConfig([]byte("verification:\n  enabled: true\n  rateLimit: 100\n" + extra +
+		"  verifiers:\n    github:\n      baseUrl: " + baseUrl + "\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+	return cfg
+}
+
+func TestFindSecretsVerifiesSecrets(t *testing.T) {
+	var requests int32
+	srv := newGithubStandIn(t, &requests)
+
+	dir := makeSourceTree(t, map[string]string{
+		"a.go": "token := \"" + liveGithubToken + "\"\n",
+		"b.go": "token := \"" + revokedGithubToken + "\"\n",
+		"c.go": "token := \"" + flakyGithubToken + "\"\n",
+		"d.go": "again := \"" + liveGithubToken + "\"\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(verificationConfig(t, srv.URL, ""))
+	findings := sf.FindSecrets(dir)
+
+	expected := map[string]string{
+		"/a.go": engine.VerificationVerified,
+		"/b.go": engine.VerificationInvalid,
+		"/c.go": engine.VerificationUnknown,
+		"/d.go": engine.VerificationVerified,
+	}
+	for path, status := range expected {
+		if fi := githubFinding(findings, path); fi == nil || fi.Verification != status {
+			t.Errorf("Expected the finding in %v to be %v. Got %v\n", path, status, fi)
+		}
+	}
+
+	if fi := githubFinding(findings, "/a.go"); fi != nil && (fi.Metadata.Confidence != "HIGH" || fi.Metadata.Score != 100) {
+		t.Errorf("Expected verified findings to be of the highest confidence. Got %v\n", fi.Metadata)
+	}
+
+	if requests != 3 {
+		t.Errorf("Expected each secret to be verified once. Got %v requests\n", requests)
+	}
+}
+
+func TestFindSecretsVerificationIsDisabledByDefault(t *testing.T) {
+	dir := makeSourceTree(t, map[string]string{
+		"a.go": "token := \"" + liveGithubToken + "\"\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.Initialize()
+	findings := sf.FindSecrets(dir)
+
+	if fi := githubFinding(findings, "/a.go"); fi == nil || fi.Verification != "" {
+		t.Errorf("Expected the finding not to be verified. Got %v\n", fi)
+	}
+}
+
+func TestFindSecretsReportsVerificationErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	t.Cleanup(srv.Close)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	dir := makeSourceTree(t, map[string]string{
+		"a.go": "token := \"" + liveGithubToken + "\"\n",
+	})
+
+	for name, url := range map[string]string{"timeout": srv.URL, "unreachable": closed.URL} {
+		var sf engine.SecretFinder
+		sf.InitializeWithConfig(verificationConfig(t, url, "  timeout: 1\n"))
+		findings := sf.FindSecrets(dir)
+
+		if fi := githubFinding(findings, "/a.go"); fi == nil || fi.Verification != engine.VerificationError {
+			t.Errorf("Expected the %v verification to fail. Got %v\n", name, fi)
+		}
+	}
+}
+
+func TestFindSecretsRateLimitsVerification(t *testing.T) {
+	var requests int32
+	srv := newGithubStandIn(t, &requests)
+
+	dir := makeSourceTree(t, map[string]string{
+		"a.go": "token := \"" + liveGithubToken + "\"\n",
+		"b.go": "token := \"" + revokedGithubToken + "\"\n",
+		"c.go": "token := \"" + flakyGithubToken + "\"\n",
+	})
+
+	cfg, err := engine.ParseConfig([]byte("verification:\n  enabled: true\n  rateLimit: 10\n  verifiers:\n    github:\n      baseUrl: " + srv.URL + "\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	start := time.Now()
+	sf.FindSecrets(dir)
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected requests to be spaced by the rate limit. Took %v\n", elapsed)
+	}
+}
+
+type fixedVerifier struct {
+	status string
+}
+
+func (fv *fixedVerifier) Verify(ctx context.Context, secret string) (string, error) {
+	if strings.HasPrefix(secret, "svc_tok_") {
+		return fv.status, nil
+	}
+	return engine.VerificationUnknown, nil
+}
+
+func TestFindSecretsUsesRegisteredVerifiers(t *testing.T) {
+	engine.RegisterVerifier("internal", func(baseUrl string, client *http.Client) engine.Verifier {
+		return &fixedVerifier{status: engine.VerificationInvalid}
+	})
+
+	cfg, err := engine.ParseConfig([]byte(`verification:
+  enabled: true
+  verifiers:
+    internal:
+      baseUrl: http://localhost
+rules:
+  - id: C001
+    description: Internal service token
+    severity: HIGH
+    regex: 'svc_tok_[0-9a-f]{32}'
+    keywords: [svc_tok_]
+    verifier: internal
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rule pack: %v\n", err.Error())
+	}
+
+	dir := makeSourceTree(t, map[string]string{
+		"a.go": "token := \"svc_tok_3f9a1c7e5b2d8064a9e1f7c3b5d2084e\"\n",
+	})
+
+	var sf engine.SecretFinder
+	sf.InitializeWithConfig(cfg)
+	findings := sf.FindSecrets(dir)
+
+	if len(findings) != 1 || findings[0].Verification != engine.VerificationInvalid {
+		t.Errorf("Expected the registered verifier to be used. Got %v\n", findings)
+	}
+}
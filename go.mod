@@ -8,6 +8,7 @@ require (
 	github.com/hashicorp/go-memdb v1.3.3
 	github.com/joho/godotenv v1.4.0
 	github.com/lib/pq v1.10.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,119 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package swagger
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/gorilla/mux"
+)
+
+var errUploadTooLarge = errors.New("upload too large")
+
+func (a *App) UploadArchive(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid repository id")
+		return
+	}
+
+	var rr *models.RepositoryRecord
+	if rr, err = a.RepoStore.Retrieve(int64(id)); err != nil {
+		respondWithError(w, http.StatusNotFound, "repository id not found")
+		return
+	}
+
+	if rr.Info.Source != engine.SourceArchive {
+		respondWithError(w, http.StatusConflict, "repository does not accept archives")
+		return
+	}
+
+	if r.Body == nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	path, err := a.saveUpload(r)
+	if err == errUploadTooLarge {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "archive too large")
+		return
+	} else if err != nil {
+		log.Printf("Failed to store uploaded archive: %v\n", err.Error())
+		respondWithError(w, http.StatusInternalServerError, "failed to store archive")
+		return
+	}
+
+	if kind, err := engine.ArchiveFormat(path); err != nil || kind == "" {
+		os.Remove(path)
+		respondWithError(w, http.StatusBadRequest, "unsupported archive format")
+		return
+	}
+
+	si := models.DefaultScanInfo()
+	si.RepoId = int64(id)
+	si.QueuedAt = currentTimestamptz()
+
+	var sr *models.ScanRecord
+	sr, err = a.ScanStore.Insert(si)
+	if err != nil {
+		os.Remove(path)
+		log.Printf("Failed to add scan to the data store: %v\n", err.Error())
+		respondWithError(w, http.StatusInternalServerError,
+			"failed to add scan to the data store")
+		return
+	}
+
+	body := &models.ApiResponse{
+		Id:      0,
+		Message: sr.Id,
+	}
+
+	respondWithJSON(w, http.StatusCreated, body)
+
+	a.AddScanRequest(rr.Info, sr, engine.JobOptions{
+		Request: models.DefaultScanRequest(),
+		Archive: path,
+	})
+}
+
+// Helper function to stream the body of the request to a file of the
+// upload directory, up to the maximum upload size. Returns the path of
+// the file, which the caller must remove.
+func (a *App) saveUpload(r *http.Request) (string, error) {
+	if r.ContentLength > a.MaxUploadSize {
+		return "", errUploadTooLarge
+	}
+
+	file, err := os.CreateTemp(a.UploadDir, "upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, io.LimitReader(r.Body, a.MaxUploadSize+1))
+	if err == nil && n > a.MaxUploadSize {
+		err = errUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
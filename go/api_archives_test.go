@@ -0,0 +1,200 @@
+package swagger_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sw "github.com/UserProblem/reposcanner/go"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+func makeZipUpload(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("config/app.env")
+	if err != nil {
+		t.Fatalf("Could not add file to zip: %v\n", err.Error())
+	}
+	w.Write([]byte("private_key=abcdef\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Could not write zip: %v\n", err.Error())
+	}
+	return buf.Bytes()
+}
+
+func addRepository(t *testing.T, ri *models.RepositoryInfo) {
+	reqBody, _ := json.Marshal(ri)
+	req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestPostNewRepositoryWithSource(t *testing.T) {
+	app.ClearStores()
+
+	root := t.TempDir()
+	app.LocalRoots = []string{root}
+	defer func() { app.LocalRoots = nil }()
+
+	addRepository(t, &models.RepositoryInfo{Name: "upload", Source: "archive"})
+	addRepository(t, &models.RepositoryInfo{Name: "local", Source: "path", Url: filepath.Join(root, "drop")})
+
+	// local git repositories are restricted to the allowed roots as well
+	addRepository(t, &models.RepositoryInfo{Name: "clone", Source: "git", Url: "file://" + filepath.Join(root, "repo")})
+	addRepository(t, &models.RepositoryInfo{Name: "clone", Url: filepath.Join(root, "repo")})
+
+	if rr, err := app.RepoStore.Retrieve(2); err != nil || rr.Info.Source != "path" {
+		t.Errorf("Expected the source to be stored. Got %v\n", rr)
+	}
+
+	tests := []struct {
+		ri      *models.RepositoryInfo
+		message string
+	}{
+		{&models.RepositoryInfo{Name: "x", Source: "svn", Url: "http://example.com/repo"}, "invalid source"},
+		{&models.RepositoryInfo{Name: "x", Source: "path", Url: t.TempDir()}, "local path not allowed"},
+		{&models.RepositoryInfo{Name: "x", Source: "path", Url: filepath.Join(root, "..")}, "local path not allowed"},
+		{&models.RepositoryInfo{Name: "x", Source: "path", Url: "drop"}, "local path not allowed"},
+		{&models.RepositoryInfo{Name: "x", Source: "archive", Url: "not a url"}, "invalid url"},
+		{&models.RepositoryInfo{Name: "x", Source: "git", Url: "file:///etc"}, "local path not allowed"},
+		{&models.RepositoryInfo{Name: "x", Url: "/etc"}, "local path not allowed"},
+		{&models.RepositoryInfo{Name: "x", Url: "not a url"}, "invalid url"},
+	}
+
+	for _, tt := range tests {
+		reqBody, _ := json.Marshal(tt.ri)
+		req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+		if !strings.Contains(response.Body.String(), tt.message) {
+			t.Errorf("Expected error '%v' for %v. Got %v\n", tt.message, tt.ri, response.Body.String())
+		}
+	}
+}
+
+func TestUploadArchive(t *testing.T) {
+	app.ClearStores()
+
+	app.UploadDir = t.TempDir()
+	defer func() { app.UploadDir = "" }()
+
+	addRepository(t, &models.RepositoryInfo{Name: "upload", Source: "archive"})
+
+	req, _ := http.NewRequest("POST", api_version+"/repository/1/archive", bytes.NewReader(makeZipUpload(t)))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var body models.ApiResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON received as response body.")
+	}
+
+	if body.Message != sw.EncodeScanId(1) {
+		t.Errorf("Expected response message to be '%v'. Got '%v'\n", sw.EncodeScanId(1), body.Message)
+	}
+
+	if sr, err := app.ScanStore.Retrieve(sw.EncodeScanId(1)); err != nil {
+		t.Fatalf("Could not retrieve scan record\n")
+	} else if sr.Info.RepoId != 1 || sr.Info.Mode != "tree" {
+		t.Errorf("Expected a tree scan of repository 1. Got %v\n", sr.Info)
+	}
+
+	if entries, _ := os.ReadDir(app.UploadDir); len(entries) != 1 {
+		t.Errorf("Expected the archive to be kept for the scan. Got %v files\n", len(entries))
+	}
+
+	// the upload is removed once the scan is over
+	req, _ = http.NewRequest("DELETE", api_version+"/scan/"+body.Message, nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		entries, _ := os.ReadDir(app.UploadDir)
+		if len(entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the uploaded archive to be removed. Got %v files\n", len(entries))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestUploadArchiveErrors(t *testing.T) {
+	app.ClearStores()
+
+	app.UploadDir = t.TempDir()
+	defer func() { app.UploadDir = "" }()
+
+	addRepository(t, &models.RepositoryInfo{Name: "upload", Source: "archive"})
+	addRepository(t, models.DefaultRepositoryInfo())
+
+	upload := makeZipUpload(t)
+	maxSize := app.MaxUploadSize
+	defer func() { app.MaxUploadSize = maxSize }()
+
+	tests := []struct {
+		path    string
+		body    []byte
+		maxSize int64
+		code    int
+	}{
+		{"/repository/invalid/archive", upload, maxSize, http.StatusBadRequest},
+		{"/repository/3/archive", upload, maxSize, http.StatusNotFound},
+		{"/repository/2/archive", upload, maxSize, http.StatusConflict},
+		{"/repository/1/archive", []byte("just some text\n"), maxSize, http.StatusBadRequest},
+		{"/repository/1/archive", upload, int64(len(upload) - 1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		app.MaxUploadSize = tt.maxSize
+		req, _ := http.NewRequest("POST", api_version+tt.path, bytes.NewReader(tt.body))
+		response := executeRequest(req)
+
+		checkResponseCode(t, tt.code, response.Code)
+	}
+
+	if entries, _ := os.ReadDir(app.UploadDir); len(entries) != 0 {
+		t.Errorf("Expected rejected uploads to be removed. Got %v files\n", len(entries))
+	}
+
+	// archive repositories are only scanned through uploads
+	req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
+
+func TestAddScanLocalPathRequiresTreeMode(t *testing.T) {
+	app.ClearStores()
+
+	root := t.TempDir()
+	app.LocalRoots = []string{root}
+	defer func() { app.LocalRoots = nil }()
+
+	addRepository(t, &models.RepositoryInfo{Name: "local", Source: "path", Url: root})
+
+	for _, body := range []string{`{"mode": "history"}`, `{"ref": "main"}`} {
+		req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", bytes.NewBufferString(body))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+
+	req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
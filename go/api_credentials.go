@@ -0,0 +1,181 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package swagger
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/UserProblem/reposcanner/engine"
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/gorilla/mux"
+)
+
+// Credential names are short identifiers that can be used in paths.
+var validCredentialName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+func (a *App) AddCredential(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var ci models.CredentialInfo
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&ci); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if msg := validateCredential(&ci); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := a.CredentialStore.Insert(&ci); err != nil {
+		if strings.HasPrefix(err.Error(), "name already exists") {
+			respondWithError(w, http.StatusConflict, "credential already exists")
+		} else {
+			log.Printf("Failed to add credential to the data store: %v\n", err.Error())
+			respondWithError(w, http.StatusInternalServerError,
+				"failed to add credential to the data store")
+		}
+		return
+	}
+
+	body := &models.ApiResponse{
+		Message: "credential created successfully",
+	}
+	respondWithJSON(w, http.StatusCreated, body)
+}
+
+func (a *App) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !validCredentialName.MatchString(name) {
+		respondWithError(w, http.StatusBadRequest, "invalid credential name")
+		return
+	}
+
+	if err := a.CredentialStore.Delete(name); err != nil {
+		if !strings.HasPrefix(err.Error(), "name not found") {
+			log.Printf("Failed to delete credential from the data store: %v\n", err.Error())
+			respondWithError(w, http.StatusInternalServerError, "failed to delete credential")
+		} else {
+			respondWithError(w, http.StatusNotFound, "credential not found")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *App) GetCredential(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !validCredentialName.MatchString(name) {
+		respondWithError(w, http.StatusBadRequest, "invalid credential name")
+		return
+	}
+
+	ci, err := a.CredentialStore.Retrieve(name)
+	if err != nil {
+		if !strings.HasPrefix(err.Error(), "name not found") {
+			log.Printf("Failed to retrieve credential: %v\n", err.Error())
+			respondWithError(w, http.StatusInternalServerError, "failed to retrieve credential")
+		} else {
+			respondWithError(w, http.StatusNotFound, "credential not found")
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ci.Public())
+}
+
+func (a *App) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	cl, err := a.CredentialStore.List()
+	if err != nil {
+		log.Printf("Failed to retrieve credential list: %v", err.Error())
+		respondWithError(w, http.StatusInternalServerError,
+			"failed to retrieve credential list from the data store")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, cl)
+}
+
+func (a *App) ModifyCredential(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !validCredentialName.MatchString(name) {
+		respondWithError(w, http.StatusBadRequest, "invalid credential name")
+		return
+	}
+
+	if r.Body == nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var ci models.CredentialInfo
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&ci); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// the name in the path identifies the credential
+	ci.Name = name
+	if msg := validateCredential(&ci); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := a.CredentialStore.Update(&ci); err != nil {
+		if strings.HasPrefix(err.Error(), "name not found") {
+			respondWithError(w, http.StatusNotFound, "credential not found")
+		} else {
+			log.Printf("Failed to update credential: %v\n", err.Error())
+			respondWithError(w, http.StatusInternalServerError,
+				"failed to update credential")
+		}
+		return
+	}
+
+	body := &models.ApiResponse{
+		Message: "credential modified successfully",
+	}
+	respondWithJSON(w, http.StatusOK, body)
+}
+
+// Helper function to check the contents of a credential. Returns an
+// error message suitable for the response, or an empty string if the
+// credential is valid.
+func validateCredential(ci *models.CredentialInfo) string {
+	if !validCredentialName.MatchString(ci.Name) {
+		return "invalid credential name"
+	}
+
+	if err := engine.ValidateCredential(ci); err != nil {
+		return "invalid credential: " + err.Error()
+	}
+
+	return ""
+}
+
+// Helper function to retrieve the credential used to clone the
+// repository. Returns nil if the repository is public.
+func (a *App) repositoryCredential(ri *models.RepositoryInfo) (*models.CredentialInfo, error) {
+	if ri.Credential == "" {
+		return nil, nil
+	}
+	return a.CredentialStore.Retrieve(ri.Credential)
+}
@@ -0,0 +1,209 @@
+package swagger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/UserProblem/reposcanner/models"
+)
+
+func addCredential(t *testing.T, ci *models.CredentialInfo) {
+	reqBody, _ := json.Marshal(ci)
+
+	req, _ := http.NewRequest("POST", api_version+"/credential", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestAddCredential(t *testing.T) {
+	app.ClearStores()
+	addCredential(t, &models.CredentialInfo{Name: "ci-token", Type_: "token", Token: "glpat-0123456789abcdef"})
+
+	ci, err := app.CredentialStore.Retrieve("ci-token")
+	if err != nil {
+		t.Fatalf("Failed to retrieve newly created credential.\n")
+	}
+
+	if ci.Type_ != "token" || ci.Token != "glpat-0123456789abcdef" {
+		t.Errorf("Expected the token to be stored. Got %v\n", ci)
+	}
+
+	req, _ := http.NewRequest("GET", api_version+"/credential/ci-token", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if strings.Contains(response.Body.String(), "glpat-0123456789abcdef") {
+		t.Errorf("Expected the token to be left out of the response. Got %v\n", response.Body.String())
+	}
+
+	var body models.CredentialInfo
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON received as response body.")
+	}
+
+	if body.Name != "ci-token" || body.Type_ != "token" {
+		t.Errorf("Expected the credential to be described. Got %v\n", body)
+	}
+}
+
+func TestAddCredentialDuplicateName(t *testing.T) {
+	app.ClearStores()
+	addCredential(t, &models.CredentialInfo{Name: "deploy", Type_: "token", Token: "abc"})
+
+	reqBody, _ := json.Marshal(&models.CredentialInfo{Name: "deploy", Type_: "token", Token: "def"})
+	req, _ := http.NewRequest("POST", api_version+"/credential", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
+
+func TestAddInvalidCredential(t *testing.T) {
+	app.ClearStores()
+
+	bodies := []*models.CredentialInfo{
+		{Name: "", Type_: "token", Token: "abc"},
+		{Name: "with space", Type_: "token", Token: "abc"},
+		{Name: "deploy", Type_: "oauth", Token: "abc"},
+		{Name: "deploy", Type_: "token"},
+		{Name: "deploy", Type_: "basic", Username: "dev"},
+		{Name: "deploy", Type_: "ssh", PrivateKey: "not a key"},
+	}
+
+	for _, ci := range bodies {
+		reqBody, _ := json.Marshal(ci)
+		req, _ := http.NewRequest("POST", api_version+"/credential", bytes.NewBuffer(reqBody))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestListCredentials(t *testing.T) {
+	app.ClearStores()
+	addCredential(t, &models.CredentialInfo{Name: "b-basic", Type_: "basic", Username: "dev", Password: "hunter22"})
+	addCredential(t, &models.CredentialInfo{Name: "a-token", Type_: "token", Token: "ghp_secret"})
+
+	req, _ := http.NewRequest("GET", api_version+"/credentials", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if s := response.Body.String(); strings.Contains(s, "hunter22") || strings.Contains(s, "ghp_secret") {
+		t.Errorf("Expected secrets to be left out of the response. Got %v\n", s)
+	}
+
+	var body models.CredentialList
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON received as response body.")
+	}
+
+	if body.Total != 2 || len(body.Items) != 2 {
+		t.Fatalf("Expected 2 credentials. Got %v\n", body)
+	}
+
+	if body.Items[0].Name != "a-token" || body.Items[1].Name != "b-basic" || body.Items[1].Username != "dev" {
+		t.Errorf("Expected credentials ordered by name. Got %v\n", body.Items)
+	}
+}
+
+func TestModifyCredential(t *testing.T) {
+	app.ClearStores()
+	addCredential(t, &models.CredentialInfo{Name: "deploy", Type_: "token", Token: "abc"})
+
+	reqBody, _ := json.Marshal(&models.CredentialInfo{Type_: "basic", Username: "dev", Password: "def"})
+	req, _ := http.NewRequest("PUT", api_version+"/credential/deploy", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	ci, err := app.CredentialStore.Retrieve("deploy")
+	if err != nil {
+		t.Fatalf("Failed to retrieve modified credential.\n")
+	}
+
+	if ci.Type_ != "basic" || ci.Password != "def" || ci.Token != "" {
+		t.Errorf("Expected the credential to be replaced. Got %v\n", ci)
+	}
+
+	req, _ = http.NewRequest("PUT", api_version+"/credential/other", bytes.NewBuffer(reqBody))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+func TestDeleteCredential(t *testing.T) {
+	app.ClearStores()
+	addCredential(t, &models.CredentialInfo{Name: "deploy", Type_: "token", Token: "abc"})
+
+	req, _ := http.NewRequest("DELETE", api_version+"/credential/deploy", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", api_version+"/credential/deploy", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("DELETE", api_version+"/credential/deploy", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+func TestPostNewRepositoryWithCredential(t *testing.T) {
+	app.ClearStores()
+
+	newRepo := models.DefaultRepositoryInfo()
+	newRepo.Credential = "deploy"
+	reqBody, _ := json.Marshal(newRepo)
+
+	req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	addCredential(t, &models.CredentialInfo{Name: "deploy", Type_: "token", Token: "abc"})
+
+	req, _ = http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("GET", api_version+"/repository/1", nil)
+	response = executeRequest(req)
+
+	var rr models.RepositoryRecord
+	if err := json.Unmarshal(response.Body.Bytes(), &rr); err != nil {
+		t.Fatalf("Invalid JSON received as response body.")
+	}
+
+	if rr.Info.Credential != "deploy" {
+		t.Errorf("Expected the credential name to be stored. Got '%v'\n", rr.Info.Credential)
+	}
+}
+
+func TestAddScanMissingCredential(t *testing.T) {
+	app.ClearStores()
+	addCredential(t, &models.CredentialInfo{Name: "deploy", Type_: "token", Token: "abc"})
+
+	ri := models.DefaultRepositoryInfo()
+	ri.Credential = "deploy"
+	if _, err := app.RepoStore.Insert(ri); err != nil {
+		t.Fatalf("Failed to add record to the repo store.\n")
+	}
+
+	if err := app.CredentialStore.Delete("deploy"); err != nil {
+		t.Fatalf("Failed to delete credential.\n")
+	}
+
+	req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
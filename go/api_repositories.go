@@ -17,9 +17,12 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/UserProblem/reposcanner/engine"
 	"github.com/UserProblem/reposcanner/models"
 	"github.com/gorilla/mux"
 )
@@ -37,8 +40,28 @@ func (a *App) AddRepository(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := url.ParseRequestURI(ri.Url); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid url")
+	if msg := a.validateSource(&ri); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if !validExcludes(ri.Excludes) {
+		respondWithError(w, http.StatusBadRequest, "invalid exclude pattern")
+		return
+	}
+
+	if ri.SubmoduleDepth < 0 || ri.SubmoduleDepth > int32(engine.MaxSubmoduleDepth) {
+		respondWithError(w, http.StatusBadRequest, "invalid submodule depth")
+		return
+	}
+
+	if msg := validateCloneOptions(ri.CloneOptions); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if _, err := a.repositoryCredential(&ri); err != nil {
+		respondWithError(w, http.StatusBadRequest, "credential not found")
 		return
 	}
 
@@ -156,8 +179,28 @@ func (a *App) ModifyRepository(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := url.ParseRequestURI(ri.Url); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid url")
+	if msg := a.validateSource(&ri); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if !validExcludes(ri.Excludes) {
+		respondWithError(w, http.StatusBadRequest, "invalid exclude pattern")
+		return
+	}
+
+	if ri.SubmoduleDepth < 0 || ri.SubmoduleDepth > int32(engine.MaxSubmoduleDepth) {
+		respondWithError(w, http.StatusBadRequest, "invalid submodule depth")
+		return
+	}
+
+	if msg := validateCloneOptions(ri.CloneOptions); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if _, err := a.repositoryCredential(&ri); err != nil {
+		respondWithError(w, http.StatusBadRequest, "credential not found")
 		return
 	}
 
@@ -179,3 +222,84 @@ func (a *App) ModifyRepository(w http.ResponseWriter, r *http.Request) {
 	}
 	respondWithJSON(w, http.StatusOK, body)
 }
+
+// Helper function to check that each exclude pattern is a single,
+// non-blank line of gitignore syntax.
+func validExcludes(excludes []string) bool {
+	for _, p := range excludes {
+		if strings.TrimSpace(p) == "" || strings.ContainsAny(p, "\r\n") || strings.HasPrefix(p, "#") {
+			return false
+		}
+		if _, err := path.Match(strings.TrimPrefix(p, "!"), ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to check where the files of a repository are
+// scanned from. Git repositories need a valid url, which must lead
+// under one of the allowed roots if it is local, path repositories an
+// absolute directory under one of the allowed roots, and archive
+// repositories take an optional url recording where the archives come
+// from. Returns an error message suitable for the response, or an
+// empty string if the source is valid.
+func (a *App) validateSource(ri *models.RepositoryInfo) string {
+	switch ri.Source {
+	case "", engine.SourceGit:
+		u, err := url.ParseRequestURI(ri.Url)
+		if err != nil {
+			return "invalid url"
+		}
+		if u.Scheme == "" || strings.EqualFold(u.Scheme, "file") {
+			if !localPathAllowed(u.Path, a.LocalRoots) {
+				return "local path not allowed"
+			}
+		}
+	case engine.SourceArchive:
+		if _, err := url.ParseRequestURI(ri.Url); ri.Url != "" && err != nil {
+			return "invalid url"
+		}
+	case engine.SourcePath:
+		if !localPathAllowed(ri.Url, a.LocalRoots) {
+			return "local path not allowed"
+		}
+	default:
+		return "invalid source"
+	}
+
+	return ""
+}
+
+// Helper function to check whether the local path is under one of the
+// allowed roots, once the links of the path are resolved if it exists.
+func localPathAllowed(p string, roots []string) bool {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return engine.LocalPathAllowed(p, roots)
+}
+
+// Helper function to check the clone options of a repository. Returns
+// an error message suitable for the response, or an empty string if
+// the options are valid.
+func validateCloneOptions(co *models.CloneOptions) string {
+	if co == nil {
+		return ""
+	}
+
+	if co.Depth < 0 {
+		return "invalid clone depth"
+	}
+	if !validExcludes(co.SparsePaths) {
+		return "invalid sparse path"
+	}
+	if co.MaxSize < 0 {
+		return "invalid maximum size"
+	}
+	if co.Timeout < 0 {
+		return "invalid clone timeout"
+	}
+
+	return ""
+}
@@ -217,6 +217,121 @@ func TestPostNewRepositoryInvalidUrl(t *testing.T) {
 	checkResponseCode(t, http.StatusBadRequest, response.Code)
 }
 
+func TestPostNewRepositoryWithExcludes(t *testing.T) {
+	app.ClearStores()
+
+	newRepo := models.DefaultRepositoryInfo()
+	newRepo.Excludes = []string{"fixtures/", "*.min.js"}
+	reqBody, _ := json.Marshal(newRepo)
+
+	req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	rr, err := app.RepoStore.Retrieve(1)
+	if err != nil {
+		t.Fatalf("Failed to retrieve newly created repository record.\n")
+	}
+
+	if len(rr.Info.Excludes) != 2 || rr.Info.Excludes[0] != "fixtures/" || rr.Info.Excludes[1] != "*.min.js" {
+		t.Errorf("Expected excludes to be stored. Got %v\n", rr.Info.Excludes)
+	}
+}
+
+func TestPostNewRepositoryInvalidExcludes(t *testing.T) {
+	app.ClearStores()
+
+	for _, p := range []string{"", "   ", "a\nb", "# comment", "[abc"} {
+		newRepo := models.DefaultRepositoryInfo()
+		newRepo.Excludes = []string{p}
+		reqBody, _ := json.Marshal(newRepo)
+
+		req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestPostNewRepositoryWithSubmoduleDepth(t *testing.T) {
+	app.ClearStores()
+
+	newRepo := models.DefaultRepositoryInfo()
+	newRepo.SubmoduleDepth = 2
+	reqBody, _ := json.Marshal(newRepo)
+
+	req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	rr, err := app.RepoStore.Retrieve(1)
+	if err != nil {
+		t.Fatalf("Failed to retrieve newly created repository record.\n")
+	}
+
+	if rr.Info.SubmoduleDepth != 2 {
+		t.Errorf("Expected the submodule depth to be stored. Got %v\n", rr.Info.SubmoduleDepth)
+	}
+
+	for _, depth := range []int32{-1, 11} {
+		newRepo.SubmoduleDepth = depth
+		reqBody, _ := json.Marshal(newRepo)
+
+		req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestPostNewRepositoryWithCloneOptions(t *testing.T) {
+	app.ClearStores()
+
+	newRepo := models.DefaultRepositoryInfo()
+	newRepo.CloneOptions = &models.CloneOptions{
+		Depth:        1,
+		SparsePaths:  []string{"src/", "!src/generated/"},
+		SingleBranch: true,
+		MaxSize:      1 << 30,
+		Timeout:      600,
+	}
+	reqBody, _ := json.Marshal(newRepo)
+
+	req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	rr, err := app.RepoStore.Retrieve(1)
+	if err != nil {
+		t.Fatalf("Failed to retrieve newly created repository record.\n")
+	}
+
+	if co := rr.Info.CloneOptions; co == nil || co.Depth != 1 || len(co.SparsePaths) != 2 || !co.SingleBranch || co.MaxSize != 1<<30 || co.Timeout != 600 {
+		t.Errorf("Expected the clone options to be stored. Got %v\n", rr.Info.CloneOptions)
+	}
+
+	invalid := []*models.CloneOptions{
+		{Depth: -1},
+		{SparsePaths: []string{""}},
+		{SparsePaths: []string{"src/[a"}},
+		{MaxSize: -1},
+		{Timeout: -1},
+	}
+
+	for _, co := range invalid {
+		newRepo.CloneOptions = co
+		reqBody, _ := json.Marshal(newRepo)
+
+		req, _ := http.NewRequest("POST", api_version+"/repository", bytes.NewBuffer(reqBody))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
 func TestGetRepository(t *testing.T) {
 	app.ClearStores()
 	addDummyRepoRecords(t, 2)
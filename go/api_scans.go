@@ -13,12 +13,15 @@ package swagger
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/UserProblem/reposcanner/engine"
 	"github.com/UserProblem/reposcanner/models"
 	"github.com/gorilla/mux"
 )
@@ -37,9 +40,44 @@ func (a *App) AddScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req := models.DefaultScanRequest()
+	if r.Body != nil {
+		contents, _ := ioutil.ReadAll(r.Body)
+		if strings.TrimSpace(string(contents)) != "" {
+			if err := json.Unmarshal(contents, req); err != nil {
+				respondWithError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+		}
+	}
+
+	if msg := validateScanRequest(req); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	switch rr.Info.Source {
+	case engine.SourceArchive:
+		respondWithError(w, http.StatusConflict, "archive repositories are scanned by uploading an archive")
+		return
+	case engine.SourcePath:
+		if req.Mode != "tree" || req.Ref != "" || req.Commit != "" {
+			respondWithError(w, http.StatusBadRequest, "scan request requires a git repository")
+			return
+		}
+	}
+
+	cred, err := a.repositoryCredential(rr.Info)
+	if err != nil {
+		log.Printf("Cannot retrieve repository credential: %v\n", err.Error())
+		respondWithError(w, http.StatusConflict, "repository credential not found")
+		return
+	}
+
 	si := models.DefaultScanInfo()
 	si.RepoId = int64(id)
 	si.QueuedAt = currentTimestamptz()
+	si.Mode = req.Mode
 
 	var sr *models.ScanRecord
 	sr, err = a.ScanStore.Insert(si)
@@ -57,7 +95,84 @@ func (a *App) AddScan(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSON(w, http.StatusCreated, body)
 
-	a.AddScanRequest(rr.Info, sr)
+	a.AddScanRequest(rr.Info, sr, engine.JobOptions{
+		Request:    req,
+		Baseline:   a.scanBaseline(int64(id), req),
+		Credential: cred,
+	})
+}
+
+// Helper function to retrieve the results of the last scan that an
+// incremental scan of the repository can build upon. Returns nil if
+// the scan is not incremental or there is no usable baseline, in which
+// case the whole tree is scanned.
+func (a *App) scanBaseline(repoId int64, req *models.ScanRequest) *engine.Baseline {
+	if req.Mode != "incremental" {
+		return nil
+	}
+
+	sr, err := a.ScanStore.RetrieveBaseline(repoId)
+	if err != nil {
+		log.Printf("No baseline for incremental scan: %v\n", err.Error())
+		return nil
+	}
+
+	findings, err := a.ScanStore.ListFindings(sr.Id)
+	if err != nil {
+		log.Printf("Cannot retrieve baseline findings: %v\n", err.Error())
+		return nil
+	}
+
+	skipped, err := a.ScanStore.ListSkippedFiles(sr.Id)
+	if err != nil {
+		log.Printf("Cannot retrieve baseline skipped files: %v\n", err.Error())
+		return nil
+	}
+
+	return &engine.Baseline{
+		Commit:     sr.Info.Commit,
+		Findings:   findings,
+		Skipped:    skipped,
+		ConfigHash: sr.Info.ConfigHash,
+	}
+}
+
+// Refs are made of slash separated components that git accepts, such
+// as main, v1.0.0 or refs/pull/1/head. Commits are full or abbreviated
+// SHA-1 hashes.
+var validRef = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*(/[A-Za-z0-9_][A-Za-z0-9_.+-]*)*$`)
+var validCommit = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// Helper function to check the contents of a scan request. Unset
+// fields are given their default values. Returns an error message
+// suitable for the response, or an empty string if the request is
+// valid.
+func validateScanRequest(req *models.ScanRequest) string {
+	switch req.Mode {
+	case "":
+		req.Mode = "tree"
+	case "tree", "history", "incremental":
+	default:
+		return "invalid scan mode"
+	}
+
+	if req.History != nil {
+		if req.Mode != "history" {
+			return "history range requires history mode"
+		}
+		if req.History.MaxCommits < 0 {
+			return "invalid history range"
+		}
+	}
+
+	if req.Ref != "" && (!validRef.MatchString(req.Ref) || strings.Contains(req.Ref, "..")) {
+		return "invalid ref"
+	}
+	if req.Commit != "" && !validCommit.MatchString(req.Commit) {
+		return "invalid commit"
+	}
+
+	return ""
 }
 
 func (a *App) DeleteScan(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +197,7 @@ func (a *App) DeleteScan(w http.ResponseWriter, r *http.Request) {
 
 	a.RemoveScanRequest(id)
 	a.ScanStore.DeleteFindings(id)
+	a.ScanStore.DeleteSkippedFiles(id)
 }
 
 func (a *App) GetScan(w http.ResponseWriter, r *http.Request) {
@@ -92,6 +208,12 @@ func (a *App) GetScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	keep, err := findingsFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	sr, err := a.ScanStore.Retrieve(id)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "scan id not found")
@@ -99,22 +221,79 @@ func (a *App) GetScan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sres := &models.ScanResults{
-		Id:       sr.Id,
-		Info:     sr.Info,
-		Findings: make([]models.FindingsInfo, 0),
+		Id:         sr.Id,
+		Info:       sr.Info,
+		Findings:   make([]models.FindingsInfo, 0),
+		Suppressed: make([]models.FindingsInfo, 0),
+		Skipped:    make([]models.SkippedFile, 0),
 	}
 
 	if findings, err := a.ScanStore.ListFindings(id); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "cannot retrieve findings")
 	} else {
 		for _, fi := range findings {
-			sres.Findings = append(sres.Findings, *fi)
+			if !keep(fi) {
+				continue
+			}
+			if fi.Suppressed {
+				sres.Suppressed = append(sres.Suppressed, *fi)
+			} else {
+				sres.Findings = append(sres.Findings, *fi)
+			}
 		}
+		sres.SuppressedCount = int32(len(sres.Suppressed))
+	}
+
+	if skipped, err := a.ScanStore.ListSkippedFiles(id); err != nil {
+		log.Printf("Cannot retrieve skipped files: %v\n", err.Error())
+	} else {
+		for _, sf := range skipped {
+			sres.Skipped = append(sres.Skipped, *sf)
+		}
+		sres.SkippedCount = int32(len(sres.Skipped))
 	}
 
 	respondWithJSON(w, http.StatusOK, sres)
 }
 
+// Helper function to parse the query parameters of a scan results
+// request into a function that tells whether a finding is listed.
+// Findings can be filtered by minimum confidence level and score.
+func findingsFilter(r *http.Request) (func(*models.FindingsInfo) bool, error) {
+	query := r.URL.Query()
+
+	minConfidence := ""
+	if v := query.Get("confidence"); v != "" {
+		level, err := engine.ParseConfidence(v)
+		if err != nil {
+			return nil, errors.New("invalid confidence")
+		}
+		minConfidence = level
+	}
+
+	minScore := int64(0)
+	if v := query.Get("minScore"); v != "" {
+		score, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || score < 0 || score > 100 {
+			return nil, errors.New("invalid minimum score")
+		}
+		minScore = score
+	}
+
+	return func(fi *models.FindingsInfo) bool {
+		if minConfidence == "" && minScore == 0 {
+			return true
+		}
+		if fi.Metadata == nil {
+			return false
+		}
+		if minConfidence != "" && !engine.ConfidenceAtLeast(fi.Metadata.Confidence, minConfidence) {
+			return false
+		}
+		return int64(fi.Metadata.Score) >= minScore
+	}, nil
+}
+
 func (a *App) ListScans(w http.ResponseWriter, r *http.Request) {
 	var pp models.PaginationParams
 	if r.Body != nil {
@@ -74,6 +74,10 @@ func TestAddScan(t *testing.T) {
 		if sr.Info.Status != "QUEUED" {
 			t.Errorf("Expected status to be QUEUED. Got %v\n", sr.Info.Status)
 		}
+
+		if sr.Info.Mode != "tree" {
+			t.Errorf("Expected mode to be tree. Got %v\n", sr.Info.Mode)
+		}
 	}
 
 }
@@ -96,6 +100,89 @@ func TestAddScanNonExistentRepoId(t *testing.T) {
 	checkResponseCode(t, http.StatusNotFound, response.Code)
 }
 
+func TestAddScanHistoryMode(t *testing.T) {
+	app.ClearStores()
+	addDummyRepoRecords(t, 1)
+
+	reqBody := []byte(`{"mode": "history", "history": {"maxCommits": 10}}`)
+	req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// Hand the queued job over to the scanner
+	app.EngineController.RunOnce()
+
+	if sr, err := app.ScanStore.Retrieve(sw.EncodeScanId(1)); err != nil {
+		t.Fatalf("Could not retrieve scan record\n")
+	} else if sr.Info.Mode != "history" {
+		t.Errorf("Expected mode to be history. Got '%v'\n", sr.Info.Mode)
+	}
+}
+
+func TestAddScanIncrementalMode(t *testing.T) {
+	app.ClearStores()
+	addDummyRepoRecords(t, 1)
+
+	reqBody := []byte(`{"mode": "incremental"}`)
+	req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", bytes.NewBuffer(reqBody))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// Hand the queued job over to the scanner
+	app.EngineController.RunOnce()
+
+	if sr, err := app.ScanStore.Retrieve(sw.EncodeScanId(1)); err != nil {
+		t.Fatalf("Could not retrieve scan record\n")
+	} else if sr.Info.Mode != "incremental" {
+		t.Errorf("Expected mode to be incremental. Got '%v'\n", sr.Info.Mode)
+	}
+}
+
+func TestAddScanRefAndCommit(t *testing.T) {
+	app.ClearStores()
+	addDummyRepoRecords(t, 1)
+
+	bodies := []string{
+		`{"ref": "v1.0.0"}`,
+		`{"ref": "refs/pull/42/head"}`,
+		`{"ref": "release/2.x", "commit": "3f2a9c0"}`,
+		`{"mode": "history", "commit": "3f2a9c0d5e8b7a6f1c4d2e0b9a8f7e6d5c4b3a21"}`,
+	}
+
+	for _, b := range bodies {
+		req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", bytes.NewBufferString(b))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	}
+}
+
+func TestAddScanInvalidRequest(t *testing.T) {
+	app.ClearStores()
+	addDummyRepoRecords(t, 1)
+
+	bodies := []string{
+		"invalid body",
+		`{"mode": "everything"}`,
+		`{"mode": "tree", "history": {"maxCommits": 10}}`,
+		`{"mode": "history", "history": {"maxCommits": -1}}`,
+		`{"ref": "refs/pull/1/head; rm -rf /"}`,
+		`{"ref": "-upload-pack=evil"}`,
+		`{"ref": "main..dev"}`,
+		`{"commit": "not-a-sha"}`,
+		`{"commit": "3f2a9c0d5e8b7a6f1c4d2e0b9a8f7e6d5c4b3a21ff"}`,
+	}
+
+	for _, b := range bodies {
+		req, _ := http.NewRequest("POST", api_version+"/repository/1/startScan", bytes.NewBufferString(b))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
 func TestGetScan(t *testing.T) {
 	app.ClearStores()
 	addDummyScanRecords(t, 2)
@@ -139,6 +226,108 @@ func TestGetScan(t *testing.T) {
 	}
 }
 
+func TestGetScanSeparatesSuppressedFindings(t *testing.T) {
+	app.ClearStores()
+	addDummyScanRecords(t, 1)
+
+	findings := []*models.FindingsInfo{
+		{RuleId: "G001", Location: &models.FindingsLocation{Path: "/a.go"}, Snippet: "abcd****wxyz", Fingerprint: "f00d"},
+		{RuleId: "G002", Location: &models.FindingsLocation{Path: "/b.go"}, Suppressed: true},
+	}
+	if err := app.ScanStore.InsertFindings(sw.EncodeScanId(1), findings); err != nil {
+		t.Fatalf("Could not store findings: %v\n", err.Error())
+	}
+
+	req, _ := http.NewRequest("GET", api_version+"/scan/"+sw.EncodeScanId(1), nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var body models.ScanResults
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON received as response body.")
+	}
+
+	if len(body.Findings) != 1 || body.Findings[0].RuleId != "G001" {
+		t.Errorf("Expected only the G001 finding to be reported. Got %v\n", body.Findings)
+	} else if body.Findings[0].Snippet != "abcd****wxyz" || body.Findings[0].Fingerprint != "f00d" {
+		t.Errorf("Expected the snippet and fingerprint to be reported. Got %v\n", body.Findings[0])
+	}
+
+	if body.SuppressedCount != 1 || len(body.Suppressed) != 1 || body.Suppressed[0].Location.Path != "/b.go" {
+		t.Errorf("Expected the G002 finding to be reported as suppressed. Got %v\n", body.Suppressed)
+	}
+}
+
+func TestGetScanFiltersFindingsByConfidence(t *testing.T) {
+	app.ClearStores()
+	addDummyScanRecords(t, 1)
+
+	findings := []*models.FindingsInfo{
+		{RuleId: "G003", Location: &models.FindingsLocation{Path: "/a.go"}, Metadata: &models.FindingsMetadata{Confidence: "HIGH", Score: 90}},
+		{RuleId: "G013", Location: &models.FindingsLocation{Path: "/b.go"}, Metadata: &models.FindingsMetadata{Confidence: "MEDIUM", Score: 50}},
+		{RuleId: "G016", Location: &models.FindingsLocation{Path: "/c.go"}, Metadata: &models.FindingsMetadata{Confidence: "LOW", Score: 20}},
+		{RuleId: "G001", Location: &models.FindingsLocation{Path: "/d.go"}},
+	}
+	if err := app.ScanStore.InsertFindings(sw.EncodeScanId(1), findings); err != nil {
+		t.Fatalf("Could not store findings: %v\n", err.Error())
+	}
+
+	for query, expected := range map[string]int{
+		"":                               4,
+		"?confidence=low":                3,
+		"?confidence=MEDIUM":             2,
+		"?confidence=high":               1,
+		"?minScore=50":                   2,
+		"?confidence=medium&minScore=60": 1,
+	} {
+		req, _ := http.NewRequest("GET", api_version+"/scan/"+sw.EncodeScanId(1)+query, nil)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var body models.ScanResults
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Invalid JSON received as response body.")
+		}
+
+		if len(body.Findings) != expected {
+			t.Errorf("Expected %v findings for '%v'. Got %v\n", expected, query, len(body.Findings))
+		}
+	}
+
+	for _, query := range []string{"?confidence=certain", "?minScore=101", "?minScore=high"} {
+		req, _ := http.NewRequest("GET", api_version+"/scan/"+sw.EncodeScanId(1)+query, nil)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestGetScanListsSkippedFiles(t *testing.T) {
+	app.ClearStores()
+	addDummyScanRecords(t, 1)
+
+	skipped := []*models.SkippedFile{{Path: "/logo.png", Reason: "binary"}}
+	if err := app.ScanStore.InsertSkippedFiles(sw.EncodeScanId(1), skipped); err != nil {
+		t.Fatalf("Could not store skipped files: %v\n", err.Error())
+	}
+
+	req, _ := http.NewRequest("GET", api_version+"/scan/"+sw.EncodeScanId(1), nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var body models.ScanResults
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON received as response body.")
+	}
+
+	if body.SkippedCount != 1 || len(body.Skipped) != 1 || body.Skipped[0].Reason != "binary" {
+		t.Errorf("Expected /logo.png to be listed as skipped. Got %v\n", body.Skipped)
+	}
+}
+
 func TestGetScanInvalidId(t *testing.T) {
 	app.ClearStores()
 
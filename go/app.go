@@ -2,6 +2,7 @@ package swagger
 
 import (
 	"log"
+	"os"
 	"sync"
 
 	"github.com/UserProblem/reposcanner/engine"
@@ -14,8 +15,15 @@ type App struct {
 	DB               *PsqlDB
 	RepoStore        RepoStore
 	ScanStore        ScanStore
+	CredentialStore  CredentialStore
+	CredentialKey    []byte
+	EngineConfig     *engine.Config
 	EngineController engine.Controller
 	EngineScanner    engine.Scanner
+	MirrorCache      *engine.MirrorCache
+	LocalRoots       []string
+	UploadDir        string
+	MaxUploadSize    int64
 	ActiveJobs       map[string]*ScanJob
 	ActiveJobsLock   sync.RWMutex
 }
@@ -23,14 +31,28 @@ type App struct {
 type ScanJob struct {
 	Job        *engine.Job
 	CancelFlag chan bool
+	Archive    string
 }
 
 const scannerLimit int = 5
 
+// Largest archive that can be uploaded, unless configured otherwise.
+const defaultMaxUploadSize int64 = 100 << 20
+
 func (a *App) Initialize(noop bool) {
 	a.Router = a.NewRouter()
 	a.ClearStores()
 	a.EngineScanner.Initialize(scannerLimit, noop)
+	if a.EngineConfig != nil {
+		a.EngineScanner.Configure(a.EngineConfig)
+	}
+	if a.MirrorCache != nil {
+		a.EngineScanner.UseMirrorCache(a.MirrorCache)
+	}
+	a.EngineScanner.AllowLocalPaths(a.LocalRoots)
+	if a.MaxUploadSize == 0 {
+		a.MaxUploadSize = defaultMaxUploadSize
+	}
 	a.EngineController.Initialize(&a.EngineScanner)
 	a.ActiveJobs = make(map[string]*ScanJob)
 	a.ActiveJobsLock = sync.RWMutex{}
@@ -50,6 +72,23 @@ func (a *App) ClearStores() {
 		log.Fatal("Cannot initialize scan data store.\n")
 	}
 	a.ScanStore = ss
+
+	// a random key only suits stores that do not outlive the process
+	if a.CredentialKey == nil {
+		if a.DBType == "postgresql" {
+			log.Fatal("A credential key is required to store credentials in a postgresql database.\n")
+		}
+		log.Printf("No credential key configured, stored credentials will not survive a restart.\n")
+		if a.CredentialKey, err = NewCredentialKey(); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	var cs CredentialStore
+	if cs, err = NewCredentialStore(a.DBType, a.CredentialKey); err != nil {
+		log.Fatal("Cannot initialize credential data store.\n")
+	}
+	a.CredentialStore = cs
 }
 
 func (a *App) Run() {
@@ -68,11 +107,12 @@ func (a *App) CleanUp() {
 	a.EngineScanner.CleanUp()
 }
 
-func (a *App) AddScanRequest(ri *models.RepositoryInfo, sr *models.ScanRecord) {
-	job := a.EngineController.AddJob(ri)
+func (a *App) AddScanRequest(ri *models.RepositoryInfo, sr *models.ScanRecord, opts engine.JobOptions) {
+	job := a.EngineController.AddJobWithOptions(ri, opts)
 	sj := ScanJob{
 		Job:        job,
 		CancelFlag: make(chan bool),
+		Archive:    opts.Archive,
 	}
 
 	a.ActiveJobsLock.Lock()
@@ -124,17 +164,24 @@ func (a *App) ScanRequestHandler(id string) {
 					newsr = sr.Clone()
 					newsr.Info.FinishedAt = currentTimestamptz()
 					newsr.Info.Status = "FAILURE"
+					newsr.Info.Reason = jupd.Reason
 					active = false
 				case "SUCCESS":
 					newsr = sr.Clone()
 					newsr.Info.FinishedAt = currentTimestamptz()
 					newsr.Info.Status = "SUCCESS"
+					newsr.Info.Commit = jupd.Commit
+					newsr.Info.Ref = jupd.Ref
+					newsr.Info.ConfigHash = jupd.ConfigHash
 					active = false
 
 					// Save findings to the data store
 					if err := a.ScanStore.InsertFindings(id, jupd.Findings); err != nil {
 						log.Printf("Error storing findings: %v\n", err.Error())
 					}
+					if err := a.ScanStore.InsertSkippedFiles(id, jupd.Skipped); err != nil {
+						log.Printf("Error storing skipped files: %v\n", err.Error())
+					}
 				}
 
 				if err = a.ScanStore.Update(newsr); err != nil {
@@ -147,6 +194,13 @@ func (a *App) ScanRequestHandler(id string) {
 		}
 	}
 
+	// uploaded archives are only kept for the scan
+	if sj.Archive != "" {
+		if err := os.Remove(sj.Archive); err != nil {
+			log.Printf("Error removing uploaded archive: %v\n", err.Error())
+		}
+	}
+
 	a.ActiveJobsLock.Lock()
 	defer a.ActiveJobsLock.Unlock()
 	delete(a.ActiveJobs, id)
@@ -0,0 +1,98 @@
+package swagger
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// Size in bytes of the key that encrypts stored credentials.
+const CredentialKeySize = 32
+
+// CredentialCipher encrypts the secret fields of credentials before
+// they are written to a data store, using AES-256-GCM. The name of the
+// credential is authenticated along with its secrets, so a sealed value
+// cannot be moved to another credential.
+type CredentialCipher struct {
+	aead cipher.AEAD
+}
+
+func NewCredentialCipher(key []byte) (*CredentialCipher, error) {
+	if len(key) != CredentialKeySize {
+		return nil, fmt.Errorf("credential key must be %v bytes long", CredentialKeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize credential cipher: %v", err.Error())
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize credential cipher: %v", err.Error())
+	}
+
+	return &CredentialCipher{aead: aead}, nil
+}
+
+// Generate a random key suitable for NewCredentialCipher.
+func NewCredentialKey() ([]byte, error) {
+	key := make([]byte, CredentialKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("cannot generate credential key: %v", err.Error())
+	}
+	return key, nil
+}
+
+// Seal encrypts the secret fields of the credential. Returns the
+// base64 encoded nonce and ciphertext, or an empty string and an
+// error on failure.
+func (c *CredentialCipher) Seal(ci *models.CredentialInfo) (string, error) {
+	plaintext, err := json.Marshal(ci.Secrets())
+	if err != nil {
+		return "", fmt.Errorf("cannot encode credential: %v", err.Error())
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("cannot generate nonce: %v", err.Error())
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, plaintext, []byte(ci.Name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts the secret fields of the named credential sealed by
+// Seal and copies them into the given credential. Returns an error if
+// the value was sealed with another key or for another credential.
+func (c *CredentialCipher) Open(ci *models.CredentialInfo, sealed string) error {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return errors.New("malformed sealed credential")
+	}
+
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(ci.Name))
+	if err != nil {
+		return errors.New("cannot decrypt credential")
+	}
+
+	var secrets models.CredentialInfo
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("cannot decode credential: %v", err.Error())
+	}
+
+	ci.Password = secrets.Password
+	ci.Token = secrets.Token
+	ci.PrivateKey = secrets.PrivateKey
+	ci.Passphrase = secrets.Passphrase
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package swagger_test
+
+import (
+	"strings"
+	"testing"
+
+	sw "github.com/UserProblem/reposcanner/go"
+	"github.com/UserProblem/reposcanner/models"
+)
+
+func TestCredentialCipher(t *testing.T) {
+	key, err := sw.NewCredentialKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v\n", err.Error())
+	}
+
+	c, err := sw.NewCredentialCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to initialize cipher: %v\n", err.Error())
+	}
+
+	ci := &models.CredentialInfo{Name: "deploy", Type_: "basic", Username: "dev", Password: "hunter22"}
+	sealed, err := c.Seal(ci)
+	if err != nil {
+		t.Fatalf("Failed to seal credential: %v\n", err.Error())
+	}
+
+	if strings.Contains(sealed, "hunter22") {
+		t.Errorf("Expected the password to be encrypted. Got %v\n", sealed)
+	}
+
+	opened := ci.Public()
+	if err := c.Open(opened, sealed); err != nil {
+		t.Fatalf("Failed to open credential: %v\n", err.Error())
+	}
+
+	if opened.Password != "hunter22" {
+		t.Errorf("Expected the password to be restored. Got '%v'\n", opened.Password)
+	}
+
+	// sealed secrets only belong to the credential they were sealed for
+	if err := c.Open(&models.CredentialInfo{Name: "other"}, sealed); err == nil {
+		t.Errorf("Expected opening under another name to fail.\n")
+	}
+
+	otherKey, _ := sw.NewCredentialKey()
+	other, _ := sw.NewCredentialCipher(otherKey)
+	if err := other.Open(ci.Public(), sealed); err == nil {
+		t.Errorf("Expected opening with another key to fail.\n")
+	}
+
+	if _, err := sw.NewCredentialCipher(key[:16]); err == nil {
+		t.Errorf("Expected a short key to be rejected.\n")
+	}
+}
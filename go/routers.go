@@ -83,6 +83,48 @@ func (a *App) NewRouter() HttpRouter {
 			a.AddScan,
 		},
 
+		Route{
+			"UploadArchive",
+			strings.ToUpper("Post"),
+			api_version + "/repository/{id}/archive",
+			a.UploadArchive,
+		},
+
+		Route{
+			"AddCredential",
+			strings.ToUpper("Post"),
+			api_version + "/credential",
+			a.AddCredential,
+		},
+
+		Route{
+			"DeleteCredential",
+			strings.ToUpper("Delete"),
+			api_version + "/credential/{name}",
+			a.DeleteCredential,
+		},
+
+		Route{
+			"GetCredential",
+			strings.ToUpper("Get"),
+			api_version + "/credential/{name}",
+			a.GetCredential,
+		},
+
+		Route{
+			"ListCredentials",
+			strings.ToUpper("Get"),
+			api_version + "/credentials",
+			a.ListCredentials,
+		},
+
+		Route{
+			"ModifyCredential",
+			strings.ToUpper("Put"),
+			api_version + "/credential/{name}",
+			a.ModifyCredential,
+		},
+
 		Route{
 			"DeleteScan",
 			strings.ToUpper("Delete"),
@@ -0,0 +1,32 @@
+package swagger
+
+import (
+	"github.com/UserProblem/reposcanner/models"
+)
+
+// CredentialStore keeps the credentials used to clone private
+// repositories. The secret fields are encrypted before they reach the
+// underlying data store and are only decrypted by Retrieve.
+type CredentialStore interface {
+	Insert(ci *models.CredentialInfo) error
+	Retrieve(name string) (*models.CredentialInfo, error)
+	Delete(name string) error
+	Update(ci *models.CredentialInfo) error
+	List() (*models.CredentialList, error)
+}
+
+// Create and return a pointer to a new credential data store that
+// encrypts secrets with the given key. Returns nil and an error on
+// failure
+func NewCredentialStore(dbtype string, key []byte) (CredentialStore, error) {
+	c, err := NewCredentialCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbtype == "postgresql" {
+		return NewCredentialStorePsqlDB(c)
+	} else {
+		return NewCredentialStoreMemDB(c)
+	}
+}
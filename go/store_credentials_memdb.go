@@ -0,0 +1,156 @@
+package swagger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/UserProblem/reposcanner/models"
+	"github.com/hashicorp/go-memdb"
+)
+
+type CredentialStoreMemDB struct {
+	DB     *memdb.MemDB
+	cipher *CredentialCipher
+}
+
+// A credential as held in the data store, with its secret fields
+// sealed.
+type sealedCredential struct {
+	Name   string
+	Info   *models.CredentialInfo
+	Secret string
+}
+
+func NewCredentialStoreMemDB(c *CredentialCipher) (CredentialStore, error) {
+	schema := &memdb.DBSchema{
+		Tables: map[string]*memdb.TableSchema{
+			"credentials": {
+				Name: "credentials",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:    "id",
+						Unique:  true,
+						Indexer: &memdb.StringFieldIndex{Field: "Name"},
+					},
+				},
+			},
+		},
+	}
+
+	db, err := memdb.NewMemDB(schema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize db: %s", err.Error())
+	}
+
+	return &CredentialStoreMemDB{DB: db, cipher: c}, nil
+}
+
+// Add a new credential to the data store. Returns nil on success or
+// an error on failure.
+func (cs *CredentialStoreMemDB) Insert(ci *models.CredentialInfo) error {
+	if _, err := cs.find(ci.Name); err == nil {
+		return fmt.Errorf("name already exists: %v", ci.Name)
+	}
+
+	return cs.put(ci)
+}
+
+// Retrieve an existing credential from the data store, including its
+// decrypted secrets. Returns nil and an error on failure.
+func (cs *CredentialStoreMemDB) Retrieve(name string) (*models.CredentialInfo, error) {
+	sc, err := cs.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := sc.Info.Clone()
+	if err := cs.cipher.Open(ci, sc.Secret); err != nil {
+		return nil, err
+	}
+
+	return ci, nil
+}
+
+// Delete an existing credential from the data store.
+// Returns nil on success or an error on failure.
+func (cs *CredentialStoreMemDB) Delete(name string) error {
+	sc, err := cs.find(name)
+	if err != nil {
+		return err
+	}
+
+	txn := cs.DB.Txn(true)
+	if err := txn.Delete("credentials", *sc); err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to delete record: %v", err.Error())
+	}
+	txn.Commit()
+
+	return nil
+}
+
+// Replace an existing credential in the data store.
+// Returns nil on success or an error on failure.
+func (cs *CredentialStoreMemDB) Update(ci *models.CredentialInfo) error {
+	if _, err := cs.find(ci.Name); err != nil {
+		return err
+	}
+
+	return cs.put(ci)
+}
+
+// List returns all the stored credentials ordered by name, without
+// their secret fields.
+func (cs *CredentialStoreMemDB) List() (*models.CredentialList, error) {
+	cl := models.CredentialList{
+		Items: make([]models.CredentialInfo, 0),
+	}
+
+	txn := cs.DB.Txn(false)
+	it, err := txn.Get("credentials", "id")
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve credential list: %v", err.Error())
+	}
+
+	for raw := it.Next(); raw != nil; raw = it.Next() {
+		sc := raw.(sealedCredential)
+		cl.Items = append(cl.Items, *sc.Info.Clone())
+	}
+	cl.Total = int32(len(cl.Items))
+
+	return &cl, nil
+}
+
+// Helper function to retrieve the sealed record of a credential.
+func (cs *CredentialStoreMemDB) find(name string) (*sealedCredential, error) {
+	txn := cs.DB.Txn(false)
+	raw, err := txn.First("credentials", "id", name)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving data from the DB. Name %v", name)
+	}
+
+	if raw == nil {
+		return nil, fmt.Errorf("name not found: %v", name)
+	}
+
+	sc := raw.(sealedCredential)
+	return &sc, nil
+}
+
+// Helper function to seal the secrets of a credential and write it
+// to the data store, replacing any record with the same name.
+func (cs *CredentialStoreMemDB) put(ci *models.CredentialInfo) error {
+	secret, err := cs.cipher.Seal(ci)
+	if err != nil {
+		return err
+	}
+
+	txn := cs.DB.Txn(true)
+	if err := txn.Insert("credentials", sealedCredential{Name: ci.Name, Info: ci.Public(), Secret: secret}); err != nil {
+		txn.Abort()
+		return errors.New("error inserting data to the DB")
+	}
+	txn.Commit()
+
+	return nil
+}
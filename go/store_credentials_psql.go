@@ -0,0 +1,155 @@
+package swagger
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/UserProblem/reposcanner/models"
+)
+
+type CredentialStorePsql struct {
+	DB     *sql.DB
+	cipher *CredentialCipher
+}
+
+func NewCredentialStorePsqlDB(c *CredentialCipher) (CredentialStore, error) {
+	actualDB := GetPsqlDBInstance().DB
+	if actualDB == nil {
+		return nil, fmt.Errorf("cannot retrieve psql instance")
+	}
+
+	createTableQuery := `CREATE TABLE IF NOT EXISTS credentials 
+	(
+		name TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		username TEXT NOT NULL,
+		knownHosts TEXT NOT NULL,
+		secret TEXT NOT NULL
+	)`
+
+	if _, err := actualDB.Exec(createTableQuery); err != nil {
+		return nil, fmt.Errorf("could not create table 'credentials': %v", err.Error())
+	}
+
+	return &CredentialStorePsql{
+		DB:     actualDB,
+		cipher: c,
+	}, nil
+}
+
+// Add a new credential to the data store. Returns nil on success or
+// an error on failure.
+func (cs *CredentialStorePsql) Insert(ci *models.CredentialInfo) error {
+	secret, err := cs.cipher.Seal(ci)
+	if err != nil {
+		return err
+	}
+
+	res, err := cs.DB.Exec(
+		"INSERT INTO credentials(name, type, username, knownHosts, secret) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (name) DO NOTHING",
+		ci.Name, ci.Type_, ci.Username, ci.KnownHosts, secret)
+
+	if err != nil {
+		return fmt.Errorf("error inserting data to the DB")
+	}
+
+	if count, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error inserting data to the DB")
+	} else if count == 0 {
+		return fmt.Errorf("name already exists: %v", ci.Name)
+	}
+
+	return nil
+}
+
+// Retrieve an existing credential from the data store, including its
+// decrypted secrets. Returns nil and an error on failure.
+func (cs *CredentialStorePsql) Retrieve(name string) (*models.CredentialInfo, error) {
+	ci := models.CredentialInfo{Name: name}
+	var secret string
+
+	err := cs.DB.QueryRow("SELECT type, username, knownHosts, secret FROM credentials WHERE name=$1",
+		name).Scan(&ci.Type_, &ci.Username, &ci.KnownHosts, &secret)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("name not found: %v", name)
+		} else {
+			return nil, fmt.Errorf("error retrieving data from the DB. Name %v", name)
+		}
+	}
+
+	if err := cs.cipher.Open(&ci, secret); err != nil {
+		return nil, err
+	}
+
+	return &ci, nil
+}
+
+// Delete an existing credential from the data store.
+// Returns nil on success or an error on failure.
+func (cs *CredentialStorePsql) Delete(name string) error {
+	res, err := cs.DB.Exec("DELETE FROM credentials WHERE name=$1", name)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete record: %v", err.Error())
+	}
+
+	if count, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete record: %v", err.Error())
+	} else if count == 0 {
+		return fmt.Errorf("name not found: %v", name)
+	}
+
+	return nil
+}
+
+// Replace an existing credential in the data store.
+// Returns nil on success or an error on failure.
+func (cs *CredentialStorePsql) Update(ci *models.CredentialInfo) error {
+	secret, err := cs.cipher.Seal(ci)
+	if err != nil {
+		return err
+	}
+
+	res, err := cs.DB.Exec("UPDATE credentials SET type=$1, username=$2, knownHosts=$3, secret=$4 WHERE name=$5",
+		ci.Type_, ci.Username, ci.KnownHosts, secret, ci.Name)
+
+	if err != nil {
+		return fmt.Errorf("failed to update record: %v", err.Error())
+	}
+
+	if count, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update record: %v", err.Error())
+	} else if count == 0 {
+		return fmt.Errorf("name not found: %v", ci.Name)
+	}
+
+	return nil
+}
+
+// List returns all the stored credentials ordered by name, without
+// their secret fields.
+func (cs *CredentialStorePsql) List() (*models.CredentialList, error) {
+	rows, err := cs.DB.Query("SELECT name, type, username, knownHosts FROM credentials ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve credential list: %v", err.Error())
+	}
+
+	defer rows.Close()
+
+	cl := models.CredentialList{
+		Items: make([]models.CredentialInfo, 0),
+	}
+
+	for rows.Next() {
+		var ci models.CredentialInfo
+		if err := rows.Scan(&ci.Name, &ci.Type_, &ci.Username, &ci.KnownHosts); err != nil {
+			return nil, fmt.Errorf("cannot retrieve credential list: %v", err.Error())
+		}
+		cl.Items = append(cl.Items, ci)
+	}
+	cl.Total = int32(len(cl.Items))
+
+	return &cl, nil
+}
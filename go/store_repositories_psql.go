@@ -2,10 +2,12 @@ package swagger
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 
 	"github.com/UserProblem/reposcanner/models"
+	"github.com/lib/pq"
 )
 
 type RepoStorePsql struct {
@@ -26,10 +28,21 @@ func NewRepoStorePsqlDB() (RepoStore, error) {
 		branch TEXT NOT NULL
 	)`
 
+	alterTableQuery := `ALTER TABLE repositories
+		ADD COLUMN IF NOT EXISTS excludes TEXT[] NOT NULL DEFAULT '{}',
+		ADD COLUMN IF NOT EXISTS submoduleDepth INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS credential TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS cloneOptions JSONB,
+		ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT ''`
+
 	if _, err := actualDB.Exec(createTableQuery); err != nil {
 		return nil, fmt.Errorf("could not create table 'repositories': %v", err.Error())
 	}
 
+	if _, err := actualDB.Exec(alterTableQuery); err != nil {
+		return nil, fmt.Errorf("could not alter table 'repositories': %v", err.Error())
+	}
+
 	return &RepoStorePsql{
 		DB: actualDB,
 	}, nil
@@ -41,8 +54,8 @@ func (rs *RepoStorePsql) Insert(ri *models.RepositoryInfo) (*models.RepositoryRe
 	var id int
 
 	err := rs.DB.QueryRow(
-		"INSERT INTO repositories(name, url, branch) VALUES ($1, $2, $3) RETURNING id",
-		ri.Name, ri.Url, ri.Branch).Scan(&id)
+		"INSERT INTO repositories(name, url, branch, excludes, submoduleDepth, credential, cloneOptions, source) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		ri.Name, ri.Url, ri.Branch, pq.Array(excludesOrEmpty(ri.Excludes)), ri.SubmoduleDepth, ri.Credential, encodeCloneOptions(ri.CloneOptions), ri.Source).Scan(&id)
 
 	if err != nil {
 		return nil, fmt.Errorf("error inserting data to the DB")
@@ -59,9 +72,10 @@ func (rs *RepoStorePsql) Insert(ri *models.RepositoryInfo) (*models.RepositoryRe
 // or nil and an error on failure.
 func (rs *RepoStorePsql) Retrieve(id int64) (*models.RepositoryRecord, error) {
 	var ri models.RepositoryInfo
+	var cloneOptions []byte
 
-	err := rs.DB.QueryRow("SELECT name, url, branch FROM repositories WHERE id=$1",
-		int(id)).Scan(&ri.Name, &ri.Url, &ri.Branch)
+	err := rs.DB.QueryRow("SELECT name, url, branch, excludes, submoduleDepth, credential, cloneOptions, source FROM repositories WHERE id=$1",
+		int(id)).Scan(&ri.Name, &ri.Url, &ri.Branch, pq.Array(&ri.Excludes), &ri.SubmoduleDepth, &ri.Credential, &cloneOptions, &ri.Source)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -71,6 +85,10 @@ func (rs *RepoStorePsql) Retrieve(id int64) (*models.RepositoryRecord, error) {
 		}
 	}
 
+	if ri.CloneOptions, err = decodeCloneOptions(cloneOptions); err != nil {
+		return nil, fmt.Errorf("error retrieving data from the DB. Id %v", id)
+	}
+
 	return &models.RepositoryRecord{
 		Id:   id,
 		Info: ri.Clone(),
@@ -98,8 +116,8 @@ func (rs *RepoStorePsql) Delete(id int64) error {
 // Update an existing repository record in the data store.
 // Returns nil on success or an error on failure.
 func (rs *RepoStorePsql) Update(rr *models.RepositoryRecord) error {
-	res, err := rs.DB.Exec("UPDATE repositories SET name=$1, url=$2, branch=$3 WHERE id=$4",
-		rr.Info.Name, rr.Info.Url, rr.Info.Branch, rr.Id)
+	res, err := rs.DB.Exec("UPDATE repositories SET name=$1, url=$2, branch=$3, excludes=$4, submoduleDepth=$5, credential=$6, cloneOptions=$7, source=$8 WHERE id=$9",
+		rr.Info.Name, rr.Info.Url, rr.Info.Branch, pq.Array(excludesOrEmpty(rr.Info.Excludes)), rr.Info.SubmoduleDepth, rr.Info.Credential, encodeCloneOptions(rr.Info.CloneOptions), rr.Info.Source, rr.Id)
 
 	if err != nil {
 		return fmt.Errorf("failed to update record: %v", err.Error())
@@ -134,7 +152,7 @@ func (rs *RepoStorePsql) List(pp *models.PaginationParams) (*models.RepositoryLi
 	}
 
 	rows, err := rs.DB.Query(
-		"SELECT id, name, url, branch FROM repositories LIMIT $1 OFFSET $2",
+		"SELECT id, name, url, branch, excludes, submoduleDepth, credential, cloneOptions, source FROM repositories LIMIT $1 OFFSET $2",
 		int(pp.PageSize), int(pp.Offset))
 
 	if err != nil {
@@ -152,8 +170,13 @@ func (rs *RepoStorePsql) List(pp *models.PaginationParams) (*models.RepositoryLi
 	for rows.Next() {
 		var rr models.RepositoryRecord
 		var ri models.RepositoryInfo
+		var cloneOptions []byte
 
-		if err := rows.Scan(&rr.Id, &ri.Name, &ri.Url, &ri.Branch); err != nil {
+		if err := rows.Scan(&rr.Id, &ri.Name, &ri.Url, &ri.Branch, pq.Array(&ri.Excludes), &ri.SubmoduleDepth, &ri.Credential, &cloneOptions, &ri.Source); err != nil {
+			return nil, fmt.Errorf("cannot retrieve repository list: %v", err.Error())
+		}
+
+		if ri.CloneOptions, err = decodeCloneOptions(cloneOptions); err != nil {
 			return nil, fmt.Errorf("cannot retrieve repository list: %v", err.Error())
 		}
 
@@ -163,3 +186,36 @@ func (rs *RepoStorePsql) List(pp *models.PaginationParams) (*models.RepositoryLi
 
 	return &rl, nil
 }
+
+// Helper function to store a missing exclude list as an empty array,
+// since the column does not accept nulls.
+func excludesOrEmpty(excludes []string) []string {
+	if excludes == nil {
+		return []string{}
+	}
+	return excludes
+}
+
+// Helper function to store the clone options as JSON, or as null if
+// the repository has none.
+func encodeCloneOptions(co *models.CloneOptions) interface{} {
+	if co == nil {
+		return nil
+	}
+
+	data, _ := json.Marshal(co)
+	return data
+}
+
+// Helper function to read the clone options stored as JSON.
+func decodeCloneOptions(data []byte) (*models.CloneOptions, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	var co models.CloneOptions
+	if err := json.Unmarshal(data, &co); err != nil {
+		return nil, err
+	}
+	return &co, nil
+}
@@ -13,9 +13,13 @@ type ScanStore interface {
 	Delete(id string) error
 	Update(sr *models.ScanRecord) error
 	List(pp *models.PaginationParams) (*models.ScanList, error)
+	RetrieveBaseline(repoId int64) (*models.ScanRecord, error)
 	InsertFindings(scanId string, findings []*models.FindingsInfo) error
 	ListFindings(scanId string) ([]*models.FindingsInfo, error)
 	DeleteFindings(scanId string) (int, error)
+	InsertSkippedFiles(scanId string, skipped []*models.SkippedFile) error
+	ListSkippedFiles(scanId string) ([]*models.SkippedFile, error)
+	DeleteSkippedFiles(scanId string) (int, error)
 }
 
 // Create and return a pointer to a new scan data store.
@@ -28,6 +32,17 @@ func NewScanStore(dbtype string) (ScanStore, error) {
 	}
 }
 
+// Helper function to check whether a scan record can be used as the
+// baseline of an incremental scan, i.e. it successfully scanned the
+// whole tree at a known commit.
+func isBaselineScan(si *models.ScanInfo) bool {
+	if si.Status != "SUCCESS" || si.Commit == "" {
+		return false
+	}
+
+	return si.Mode == "" || si.Mode == "tree" || si.Mode == "incremental"
+}
+
 // Helper function to convert a numeric value into a base64
 // string value that can be used as an id
 func EncodeScanId(v uint64) string {
@@ -3,6 +3,7 @@ package swagger
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/UserProblem/reposcanner/models"
 	"github.com/hashicorp/go-memdb"
@@ -13,6 +14,7 @@ type ScanStoreMemDB struct {
 	nextId         chan uint64
 	total          int32
 	nextFindingsId chan uint64
+	nextSkippedId  chan uint64
 }
 
 func NewScanStoreMemDB() (ScanStore, error) {
@@ -43,6 +45,21 @@ func NewScanStoreMemDB() (ScanStore, error) {
 					},
 				},
 			},
+			"skipped": {
+				Name: "skipped",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:    "id",
+						Unique:  true,
+						Indexer: &memdb.IntFieldIndex{Field: "Id"},
+					},
+					"scanid": {
+						Name:    "scanid",
+						Unique:  false,
+						Indexer: &memdb.StringFieldIndex{Field: "ScanId", Lowercase: false},
+					},
+				},
+			},
 		},
 	}
 
@@ -51,15 +68,17 @@ func NewScanStoreMemDB() (ScanStore, error) {
 		return nil, fmt.Errorf("cannot initialize db: %s", err.Error())
 	}
 
-	chS, chF := make(chan uint64), make(chan uint64)
+	chS, chF, chK := make(chan uint64), make(chan uint64), make(chan uint64)
 	go generateObjIds(chS)
 	go generateObjIds(chF)
+	go generateObjIds(chK)
 
 	return &ScanStoreMemDB{
 		DB:             db,
 		nextId:         chS,
 		total:          0,
 		nextFindingsId: chF,
+		nextSkippedId:  chK,
 	}, nil
 }
 
@@ -194,6 +213,42 @@ func (ss *ScanStoreMemDB) List(pp *models.PaginationParams) (*models.ScanList, e
 	return &sl, nil
 }
 
+// RetrieveBaseline returns the most recent scan of the given
+// repository that can serve as the baseline of an incremental scan.
+// Returns nil and an error if there is no such scan.
+func (ss *ScanStoreMemDB) RetrieveBaseline(repoId int64) (*models.ScanRecord, error) {
+	txn := ss.DB.Txn(false)
+	it, err := txn.Get("scans", "id")
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve scan list: %v", err.Error())
+	}
+
+	var latest *models.ScanRecord
+	var latestAt time.Time
+	for raw := it.Next(); raw != nil; raw = it.Next() {
+		sr := raw.(models.ScanRecord)
+		if sr.Info.RepoId != repoId || !isBaselineScan(sr.Info) {
+			continue
+		}
+
+		finishedAt, err := time.Parse(time.RFC3339, sr.Info.FinishedAt)
+		if err != nil {
+			continue
+		}
+
+		if latest == nil || finishedAt.After(latestAt) {
+			latest = sr.Clone()
+			latestAt = finishedAt
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("no baseline scan for repository %v", repoId)
+	}
+
+	return latest, nil
+}
+
 // Helper function to auto-generate the next unique id value
 // that can be used for new findings records.
 func (ss *ScanStoreMemDB) NextFindingsId() int {
@@ -267,3 +322,72 @@ func (ss *ScanStoreMemDB) DeleteFindings(scanId string) (int, error) {
 	txn.Commit()
 	return count, nil
 }
+
+// Helper function to auto-generate the next unique id value
+// that can be used for new skipped file records.
+func (ss *ScanStoreMemDB) NextSkippedId() int {
+	return int(<-ss.nextSkippedId)
+}
+
+// InsertSkippedFiles stores the list of files that were not
+// scanned, indexed by scanId. It returns nil on success or an
+// error on failure, at which point none of the files will be
+// stored.
+func (ss *ScanStoreMemDB) InsertSkippedFiles(scanId string, skipped []*models.SkippedFile) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+
+	txn := ss.DB.Txn(true)
+
+	for _, sf := range skipped {
+		kr := models.SkippedFileRecord{
+			Id:     ss.NextSkippedId(),
+			ScanId: scanId,
+			File:   sf,
+		}
+
+		if err := txn.Insert("skipped", kr); err != nil {
+			txn.Abort()
+			return fmt.Errorf("error inserting data to the DB: %v", kr)
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// ListSkippedFiles retrieves the list of files that were not
+// scanned, indexed by scanId. It returns the list on success, or
+// nil and an error on failure.
+func (ss *ScanStoreMemDB) ListSkippedFiles(scanId string) ([]*models.SkippedFile, error) {
+	txn := ss.DB.Txn(false)
+
+	it, err := txn.Get("skipped", "scanid", scanId)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve skipped files list: %v", err.Error())
+	}
+
+	skipped := make([]*models.SkippedFile, 0)
+	for kr := it.Next(); kr != nil; kr = it.Next() {
+		skipped = append(skipped, kr.(models.SkippedFileRecord).File)
+	}
+
+	return skipped, nil
+}
+
+// DeleteSkippedFiles deletes the list of files that were not
+// scanned, indexed by scanId. It returns the number of deleted
+// records on success, or zero and an error on failure.
+func (ss *ScanStoreMemDB) DeleteSkippedFiles(scanId string) (int, error) {
+	txn := ss.DB.Txn(true)
+
+	count, err := txn.DeleteAll("skipped", "scanid", scanId)
+	if err != nil {
+		txn.Abort()
+		return 0, fmt.Errorf("cannot delete all skipped files: %v", err.Error())
+	}
+
+	txn.Commit()
+	return count, nil
+}
@@ -36,6 +36,13 @@ func NewScanStorePsqlDB() (ScanStore, error) {
 		status enum_status NOT NULL
 	)`
 
+	alterScanTableQuery := `ALTER TABLE scans
+		ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'tree',
+		ADD COLUMN IF NOT EXISTS commitSha TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS refName TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS configHash TEXT NOT NULL DEFAULT ''`
+
 	createFindingsTableQuery := `CREATE TABLE IF NOT EXISTS findings
 	(
 		id SERIAL PRIMARY KEY,
@@ -43,6 +50,14 @@ func NewScanStorePsqlDB() (ScanStore, error) {
 		finding JSONB NOT NULL
 	)`
 
+	createSkippedTableQuery := `CREATE TABLE IF NOT EXISTS skipped_files
+	(
+		id SERIAL PRIMARY KEY,
+		scanId TEXT NOT NULL REFERENCES scans(id),
+		path TEXT NOT NULL,
+		reason TEXT NOT NULL
+	)`
+
 	if _, err := actualDB.Exec(createEnumStatusQuery); err != nil {
 		if !strings.HasSuffix(err.Error(), "already exists") {
 			return nil, fmt.Errorf("could not create enum 'enum_status': %v", err.Error())
@@ -53,10 +68,18 @@ func NewScanStorePsqlDB() (ScanStore, error) {
 		return nil, fmt.Errorf("could not create table 'scans': %v", err.Error())
 	}
 
+	if _, err := actualDB.Exec(alterScanTableQuery); err != nil {
+		return nil, fmt.Errorf("could not alter table 'scans': %v", err.Error())
+	}
+
 	if _, err := actualDB.Exec(createFindingsTableQuery); err != nil {
 		return nil, fmt.Errorf("could not create table 'findings': %v", err.Error())
 	}
 
+	if _, err := actualDB.Exec(createSkippedTableQuery); err != nil {
+		return nil, fmt.Errorf("could not create table 'skipped_files': %v", err.Error())
+	}
+
 	chS, chF := make(chan uint64), make(chan uint64)
 	go generateObjIds(chS)
 	go generateObjIds(chF)
@@ -102,9 +125,9 @@ func (ss *ScanStorePsqlDB) Insert(si *models.ScanInfo) (*models.ScanRecord, erro
 
 	var res string
 	err := ss.DB.QueryRow(
-		`INSERT INTO scans(id, repoId, queuedAt, scanningAt, finishedAt, status)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
-		id, si.RepoId, si.QueuedAt, scanningAt, finishedAt, si.Status).Scan(&res)
+		`INSERT INTO scans(id, repoId, queuedAt, scanningAt, finishedAt, status, mode, commitSha, refName, reason, configHash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		id, si.RepoId, si.QueuedAt, scanningAt, finishedAt, si.Status, si.Mode, si.Commit, si.Ref, si.Reason, si.ConfigHash).Scan(&res)
 
 	if err != nil {
 		return nil, fmt.Errorf("error inserting data to the DB: %v", err.Error())
@@ -128,8 +151,8 @@ func (ss *ScanStorePsqlDB) Retrieve(id string) (*models.ScanRecord, error) {
 
 	var scanningAt, finishedAt *string
 
-	err := ss.DB.QueryRow("SELECT repoId, queuedAt, scanningAt, finishedAt, status FROM scans WHERE id=$1",
-		id).Scan(&si.RepoId, &si.QueuedAt, &scanningAt, &finishedAt, &si.Status)
+	err := ss.DB.QueryRow("SELECT repoId, queuedAt, scanningAt, finishedAt, status, mode, commitSha, refName, reason, configHash FROM scans WHERE id=$1",
+		id).Scan(&si.RepoId, &si.QueuedAt, &scanningAt, &finishedAt, &si.Status, &si.Mode, &si.Commit, &si.Ref, &si.Reason, &si.ConfigHash)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -165,6 +188,9 @@ func (ss *ScanStorePsqlDB) Delete(id string) error {
 			if _, err = ss.DeleteFindings(id); err != nil {
 				return fmt.Errorf("failed to delete related findings: %v", err.Error())
 			}
+			if _, err = ss.DeleteSkippedFiles(id); err != nil {
+				return fmt.Errorf("failed to delete related skipped files: %v", err.Error())
+			}
 		}
 	}
 
@@ -200,8 +226,8 @@ func (ss *ScanStorePsqlDB) Update(sr *models.ScanRecord) error {
 		finishedAt = &sr.Info.FinishedAt
 	}
 
-	res, err := ss.DB.Exec("UPDATE scans SET repoId=$1, queuedAt=$2, scanningAt=$3, finishedAt=$4, status=$5 WHERE id=$6",
-		sr.Info.RepoId, sr.Info.QueuedAt, scanningAt, finishedAt, sr.Info.Status, sr.Id)
+	res, err := ss.DB.Exec("UPDATE scans SET repoId=$1, queuedAt=$2, scanningAt=$3, finishedAt=$4, status=$5, mode=$6, commitSha=$7, refName=$8, reason=$9, configHash=$10 WHERE id=$11",
+		sr.Info.RepoId, sr.Info.QueuedAt, scanningAt, finishedAt, sr.Info.Status, sr.Info.Mode, sr.Info.Commit, sr.Info.Ref, sr.Info.Reason, sr.Info.ConfigHash, sr.Id)
 
 	if err != nil {
 		return fmt.Errorf("failed to update record: %v", err.Error())
@@ -236,7 +262,7 @@ func (ss *ScanStorePsqlDB) List(pp *models.PaginationParams) (*models.ScanList,
 	}
 
 	rows, err := ss.DB.Query(
-		"SELECT id, repoId, queuedAt, scanningAt, finishedAt, status FROM scans LIMIT $1 OFFSET $2",
+		"SELECT id, repoId, queuedAt, scanningAt, finishedAt, status, mode, commitSha, refName, reason, configHash FROM scans LIMIT $1 OFFSET $2",
 		int(pp.PageSize), int(pp.Offset))
 
 	if err != nil {
@@ -256,7 +282,7 @@ func (ss *ScanStorePsqlDB) List(pp *models.PaginationParams) (*models.ScanList,
 		var si models.ScanInfo
 		var scanningAt, finishedAt *string
 
-		if err := rows.Scan(&sr.Id, &si.RepoId, &si.QueuedAt, &scanningAt, &finishedAt, &si.Status); err != nil {
+		if err := rows.Scan(&sr.Id, &si.RepoId, &si.QueuedAt, &scanningAt, &finishedAt, &si.Status, &si.Mode, &si.Commit, &si.Ref, &si.Reason, &si.ConfigHash); err != nil {
 			return nil, fmt.Errorf("cannot retrieve scan list: %v", err.Error())
 		}
 
@@ -279,6 +305,28 @@ func (ss *ScanStorePsqlDB) List(pp *models.PaginationParams) (*models.ScanList,
 	return &sl, nil
 }
 
+// RetrieveBaseline returns the most recent scan of the given
+// repository that can serve as the baseline of an incremental scan.
+// Returns nil and an error if there is no such scan.
+func (ss *ScanStorePsqlDB) RetrieveBaseline(repoId int64) (*models.ScanRecord, error) {
+	var id string
+	err := ss.DB.QueryRow(
+		`SELECT id FROM scans
+		WHERE repoId=$1 AND status='SUCCESS' AND commitSha<>'' AND mode IN ('tree', 'incremental')
+		ORDER BY finishedAt DESC LIMIT 1`,
+		repoId).Scan(&id)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no baseline scan for repository %v", repoId)
+		} else {
+			return nil, fmt.Errorf("error retrieving data from the DB: %v", err.Error())
+		}
+	}
+
+	return ss.Retrieve(id)
+}
+
 // InsertFindings stores all of the contents of the findings
 // list into the data store, indexed by scanId. All operations
 // related to findings are done in bulk. It returns nil on success
@@ -368,3 +416,72 @@ func (ss *ScanStorePsqlDB) DeleteFindings(scanId string) (int, error) {
 	txn.Commit()
 	return int(count), nil
 }
+
+// InsertSkippedFiles stores the list of files that were not
+// scanned, indexed by scanId. It returns nil on success or an
+// error on failure, at which point none of the files will be
+// stored.
+func (ss *ScanStorePsqlDB) InsertSkippedFiles(scanId string, skipped []*models.SkippedFile) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	txn, err := ss.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create DB transaction: %v", err.Error())
+	}
+
+	for _, sf := range skipped {
+		if _, err := txn.Exec("INSERT INTO skipped_files(scanId, path, reason) VALUES ($1, $2, $3)",
+			scanId, sf.Path, sf.Reason); err != nil {
+			txn.Rollback()
+			return fmt.Errorf("error inserting data to the DB: %v", err.Error())
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// ListSkippedFiles retrieves the list of files that were not
+// scanned, indexed by scanId. It returns the list on success, or
+// nil and an error on failure.
+func (ss *ScanStorePsqlDB) ListSkippedFiles(scanId string) ([]*models.SkippedFile, error) {
+	rows, err := ss.DB.Query("SELECT path, reason FROM skipped_files WHERE scanId=$1 ORDER BY id", scanId)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve skipped files list: %v", err.Error())
+	}
+
+	defer rows.Close()
+
+	skipped := make([]*models.SkippedFile, 0)
+	for rows.Next() {
+		var sf models.SkippedFile
+		if err := rows.Scan(&sf.Path, &sf.Reason); err != nil {
+			return nil, fmt.Errorf("cannot retrieve skipped files list: %v", err.Error())
+		}
+
+		skipped = append(skipped, &sf)
+	}
+
+	return skipped, nil
+}
+
+// DeleteSkippedFiles deletes the list of files that were not
+// scanned, indexed by scanId. It returns the number of deleted
+// records on success, or zero and an error on failure.
+func (ss *ScanStorePsqlDB) DeleteSkippedFiles(scanId string) (int, error) {
+	res, err := ss.DB.Exec("DELETE FROM skipped_files WHERE scanId=$1", scanId)
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete all skipped files: %v", err.Error())
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete all skipped files: %v", err.Error())
+	}
+
+	return int(count), nil
+}
@@ -1,6 +1,7 @@
 package swagger_test
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -18,6 +19,9 @@ func DropScanTable(t *testing.T) {
 	if _, err := PDB.DB.Exec("DROP TABLE IF EXISTS findings CASCADE"); err != nil {
 		t.Fatalf("Failed to drop findings table: %v\n", err.Error())
 	}
+	if _, err := PDB.DB.Exec("DROP TABLE IF EXISTS skipped_files CASCADE"); err != nil {
+		t.Fatalf("Failed to drop skipped files table: %v\n", err.Error())
+	}
 }
 
 func initializeScanStore(t *testing.T) sw.ScanStore {
@@ -127,6 +131,10 @@ func TestStoreNewScanInfo(t *testing.T) {
 	if sr.Info.Status != si.Status {
 		t.Errorf("Expected status is %v. Got %v\n", si.Status, sr.Info.Status)
 	}
+
+	if sr.Info.Mode != si.Mode {
+		t.Errorf("Expected mode is %v. Got %v\n", si.Mode, sr.Info.Mode)
+	}
 }
 
 func TestRetrieveScanRecord(t *testing.T) {
@@ -356,6 +364,39 @@ func TestRetrieveScanListInvalidPageSize(t *testing.T) {
 	}
 }
 
+func TestRetrieveBaseline(t *testing.T) {
+	ss := initializeScanStore(t)
+	addDummyRepo(t)
+
+	scans := []*models.ScanInfo{
+		{RepoId: 1, FinishedAt: "2022-01-01T00:00:01Z", Status: "SUCCESS", Mode: "tree", Commit: "aaaa"},
+		{RepoId: 1, FinishedAt: "2022-01-01T00:00:03Z", Status: "SUCCESS", Mode: "incremental", Commit: "bbbb"},
+		{RepoId: 1, FinishedAt: "2022-01-01T00:00:04Z", Status: "SUCCESS", Mode: "history", Commit: "cccc"},
+		{RepoId: 1, FinishedAt: "2022-01-01T00:00:05Z", Status: "FAILURE", Mode: "tree"},
+		{RepoId: 1, Status: "QUEUED", Mode: "tree"},
+	}
+
+	for _, si := range scans {
+		si.QueuedAt = "2022-01-01T00:00:00Z"
+		if _, err := ss.Insert(si); err != nil {
+			t.Fatalf("Failed to insert scan info into the database: %v\n", err.Error())
+		}
+	}
+
+	sr, err := ss.RetrieveBaseline(1)
+	if err != nil {
+		t.Fatalf("Failed to retrieve baseline scan: %v\n", err.Error())
+	}
+
+	if sr.Id != sw.EncodeScanId(2) || sr.Info.Commit != "bbbb" {
+		t.Errorf("Expected scan %v at commit bbbb. Got %v at commit %v\n", sw.EncodeScanId(2), sr.Id, sr.Info.Commit)
+	}
+
+	if _, err := ss.RetrieveBaseline(2); err == nil {
+		t.Errorf("Expected error for repository without scans.\n")
+	}
+}
+
 func TestAddEmptyFindingsList(t *testing.T) {
 	ss := initializeScanStore(t)
 
@@ -385,6 +426,9 @@ func makeFindingsList(n int) []*models.FindingsInfo {
 				Description: "Hard-coded secret - public key",
 				Severity:    "HIGH",
 			},
+			Snippet:     "abcd****wxyz",
+			Context:     "public_key = abcd****wxyz",
+			Fingerprint: fmt.Sprintf("%064x", i),
 		}
 	}
 
@@ -466,6 +510,45 @@ func TestListFindings(t *testing.T) {
 			if expected != actual {
 				t.Errorf("Expected %v. Got %v\n", expected, actual)
 			}
+
+			if results[i].Snippet != findings[i].Snippet || results[i].Context != findings[i].Context || results[i].Fingerprint != findings[i].Fingerprint {
+				t.Errorf("Expected redacted snippet, context and fingerprint to be stored. Got %v\n", results[i])
+			}
 		}
 	}
 }
+
+func TestSkippedFiles(t *testing.T) {
+	ss := initializeScanStore(t)
+	addDummyRepo(t)
+
+	sr, err := ss.Insert(models.DefaultScanInfo())
+	if err != nil {
+		t.Fatalf("Failed to insert scan info into the database.\n")
+	}
+
+	skipped := []*models.SkippedFile{
+		{Path: "/logo.png", Reason: "binary"},
+		{Path: "/dump.sql", Reason: "too large"},
+	}
+	if err := ss.InsertSkippedFiles(sr.Id, skipped); err != nil {
+		t.Fatalf("Failed to insert skipped files: %v\n", err.Error())
+	}
+
+	results, err := ss.ListSkippedFiles(sr.Id)
+	if err != nil {
+		t.Fatalf("Failed to list skipped files: %v\n", err.Error())
+	}
+
+	if len(results) != 2 || results[0].Path != "/logo.png" || results[1].Reason != "too large" {
+		t.Errorf("Expected the skipped files to be listed. Got %v\n", results)
+	}
+
+	if n, err := ss.DeleteSkippedFiles(sr.Id); err != nil || n != 2 {
+		t.Errorf("Expected 2 skipped files to be deleted. Got %v (%v)\n", n, err)
+	}
+
+	if results, _ := ss.ListSkippedFiles(sr.Id); len(results) != 0 {
+		t.Errorf("Expected no skipped files after deletion. Got %v\n", results)
+	}
+}
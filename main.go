@@ -11,11 +11,14 @@
 package main
 
 import (
+	"encoding/base64"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 
+	"github.com/UserProblem/reposcanner/engine"
 	sw "github.com/UserProblem/reposcanner/go"
 	"github.com/joho/godotenv"
 )
@@ -27,6 +30,10 @@ func main() {
 	var app sw.App
 
 	loadDBParameters(&app)
+	loadEngineConfig(&app)
+	loadCredentialKey(&app)
+	loadMirrorCache(&app)
+	loadSources(&app)
 
 	app.Initialize(loadNoop())
 	app.Run()
@@ -56,6 +63,84 @@ func loadDBParameters(app *sw.App) {
 	}
 }
 
+func loadEngineConfig(app *sw.App) {
+	path := os.Getenv("RULES_FILE")
+	if path == "" {
+		log.Printf("Using default rule pack.")
+		return
+	}
+
+	if cfg, err := engine.LoadConfig(path); err != nil {
+		log.Fatal(err.Error())
+	} else {
+		log.Printf("Using rule pack '%v'", path)
+		app.EngineConfig = cfg
+	}
+}
+
+func loadCredentialKey(app *sw.App) {
+	encoded := os.Getenv("CREDENTIALS_KEY")
+	if encoded == "" {
+		if app.DBType == "postgresql" {
+			log.Fatal("CREDENTIALS_KEY must be set with a postgresql database")
+		}
+		return
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != sw.CredentialKeySize {
+		log.Fatalf("CREDENTIALS_KEY must be the base64 encoding of %v bytes", sw.CredentialKeySize)
+	}
+	app.CredentialKey = key
+}
+
+// Default disk quota of the mirror cache, 10 GiB.
+const defaultMirrorCacheQuota int64 = 10 << 30
+
+func loadMirrorCache(app *sw.App) {
+	dir := os.Getenv("MIRROR_CACHE_DIR")
+	if dir == "" {
+		return
+	}
+
+	quota := defaultMirrorCacheQuota
+	if v := os.Getenv("MIRROR_CACHE_QUOTA"); v != "" {
+		q, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || q < 0 {
+			log.Fatal("MIRROR_CACHE_QUOTA must be a number of bytes")
+		}
+		quota = q
+	}
+
+	cache, err := engine.NewMirrorCache(dir, quota)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Printf("Using mirror cache '%v' with a quota of %v bytes", dir, quota)
+	app.MirrorCache = cache
+}
+
+func loadSources(app *sw.App) {
+	if roots := os.Getenv("LOCAL_SOURCE_ROOTS"); roots != "" {
+		for _, root := range filepath.SplitList(roots) {
+			if !filepath.IsAbs(root) {
+				log.Fatalf("LOCAL_SOURCE_ROOTS must only hold absolute paths, got '%v'", root)
+			}
+			app.LocalRoots = append(app.LocalRoots, root)
+		}
+		log.Printf("Allowing local paths under %v", app.LocalRoots)
+	}
+
+	app.UploadDir = os.Getenv("UPLOAD_DIR")
+	if v := os.Getenv("MAX_UPLOAD_SIZE"); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size <= 0 {
+			log.Fatal("MAX_UPLOAD_SIZE must be a positive number of bytes")
+		}
+		app.MaxUploadSize = size
+	}
+}
+
 func loadNoop() bool {
 	if noop := os.Getenv("ENGINE_NOOP"); noop == "1" {
 		log.Printf("Running with no-op scanner.")
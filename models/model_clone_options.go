@@ -0,0 +1,37 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package models
+
+type CloneOptions struct {
+
+	// number of commits fetched for tree scans, 0 to fetch the whole history
+	Depth int32 `json:"depth,omitempty"`
+
+	// paths that are checked out and scanned, using the gitignore syntax
+	SparsePaths []string `json:"sparsePaths,omitempty"`
+
+	// look for a commit requested without a ref in the repository branch only
+	SingleBranch bool `json:"singleBranch,omitempty"`
+
+	// largest size in bytes of the checkout, 0 for no limit
+	MaxSize int64 `json:"maxSize,omitempty"`
+
+	// longest time in seconds the checkout can take, 0 for no limit
+	Timeout int32 `json:"timeout,omitempty"`
+}
+
+func (co *CloneOptions) Clone() *CloneOptions {
+	c := *co
+	if co.SparsePaths != nil {
+		c.SparsePaths = append([]string{}, co.SparsePaths...)
+	}
+	return &c
+}
@@ -0,0 +1,26 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package models
+
+type CommitInfo struct {
+
+	// SHA-1 hash of the commit
+	Sha string `json:"sha"`
+
+	// name of the commit author
+	Author string `json:"author"`
+
+	// email address of the commit author
+	Email string `json:"email"`
+
+	// timestamp when the commit was authored
+	Timestamp string `json:"timestamp"`
+}
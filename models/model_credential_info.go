@@ -0,0 +1,64 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package models
+
+type CredentialInfo struct {
+
+	// unique name that repositories use to refer to the credential
+	Name string `json:"name"`
+
+	// kind of credential, one of token, basic or ssh
+	Type_ string `json:"type"`
+
+	// user name sent with the credential, defaults to git
+	Username string `json:"username,omitempty"`
+
+	// password of a basic credential, never returned
+	Password string `json:"password,omitempty"`
+
+	// access token of a token credential, never returned
+	Token string `json:"token,omitempty"`
+
+	// PEM encoded private key of an ssh credential, never returned
+	PrivateKey string `json:"privateKey,omitempty"`
+
+	// passphrase of the private key, never returned
+	Passphrase string `json:"passphrase,omitempty"`
+
+	// known_hosts entries used to check the key of ssh servers
+	KnownHosts string `json:"knownHosts,omitempty"`
+}
+
+func (ci *CredentialInfo) Clone() *CredentialInfo {
+	c := *ci
+	return &c
+}
+
+// Returns a copy of the credential without its secret fields, fit to
+// be listed in API responses.
+func (ci *CredentialInfo) Public() *CredentialInfo {
+	return &CredentialInfo{
+		Name:       ci.Name,
+		Type_:      ci.Type_,
+		Username:   ci.Username,
+		KnownHosts: ci.KnownHosts,
+	}
+}
+
+// Returns a copy of the credential holding only its secret fields.
+func (ci *CredentialInfo) Secrets() *CredentialInfo {
+	return &CredentialInfo{
+		Password:   ci.Password,
+		Token:      ci.Token,
+		PrivateKey: ci.PrivateKey,
+		Passphrase: ci.Passphrase,
+	}
+}
@@ -15,10 +15,20 @@ type FileLocation struct {
 	// the start of the block of code that produced this finding
 	Begin *LineLocation `json:"begin"`
 
-	// if present, the end of the block of code that produced this finding
+	// if present, the end of the block of code that produced this finding.
+	// The end column and offset point just past the last character.
 	End *LineLocation `json:"end,omitempty"`
+
+	// if present, the number of bytes of the secret from its beginning
+	Length int64 `json:"length,omitempty"`
 }
 
 type LineLocation struct {
 	Line int32 `json:"line"`
+
+	// if present, the byte column of the position within the line, starting at 1
+	Column int32 `json:"column,omitempty"`
+
+	// if present, the byte offset of the position from the start of the file
+	Offset int64 `json:"offset,omitempty"`
 }
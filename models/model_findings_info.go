@@ -21,4 +21,28 @@ type FindingsInfo struct {
 	Location *FindingsLocation `json:"location,omitempty"`
 
 	Metadata *FindingsMetadata `json:"metadata,omitempty"`
+
+	// if present, the submodule the file of this finding belongs to
+	Submodule *SubmoduleInfo `json:"submodule,omitempty"`
+
+	// if present, the commit that introduced the code that produced this finding
+	Commit *CommitInfo `json:"commit,omitempty"`
+
+	// true if the finding was suppressed by an inline comment in the source code
+	Suppressed bool `json:"suppressed,omitempty"`
+
+	// the matched secret, with all but its first and last characters masked
+	Snippet string `json:"snippet,omitempty"`
+
+	// the source code that matched, with the secret redacted
+	Context string `json:"context,omitempty"`
+
+	// stable identifier of the secret, derived from the rule, the secret and the path
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// if present, the encoding of the text the secret was decoded from, base64 or hex
+	Encoding string `json:"encoding,omitempty"`
+
+	// if present, whether the service that issued the secret accepted it: verified, invalid, unknown or error
+	Verification string `json:"verification,omitempty"`
 }
@@ -17,4 +17,13 @@ type FindingsMetadata struct {
 
 	// classification of the security impact of this finding
 	Severity string `json:"severity"`
+
+	// for values of structured configuration files, the path of their key, such as database.prod.password
+	KeyPath string `json:"keyPath,omitempty"`
+
+	// likelihood that this finding is a real secret
+	Confidence string `json:"confidence,omitempty"`
+
+	// likelihood that this finding is a real secret, from 0 to 100
+	Score int32 `json:"score"`
 }
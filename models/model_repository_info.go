@@ -15,11 +15,25 @@ type RepositoryInfo struct {
 	// short name for the repository
 	Name string `json:"name"`
 
-	// public URL of the repository
+	// where the files are scanned from, one of git, archive or path
+	Source string `json:"source,omitempty"`
+
+	// public URL of the repository, or server-side directory of a path repository
 	Url string `json:"url"`
 
 	// branch of the repository
 	Branch string `json:"branch,omitempty"`
+
+	// paths that are not scanned, using the gitignore syntax
+	Excludes []string `json:"excludes,omitempty"`
+
+	// levels of nested submodules that are cloned and scanned, 0 to leave submodules out
+	SubmoduleDepth int32 `json:"submoduleDepth,omitempty"`
+
+	// name of the stored credential used to clone the repository
+	Credential string `json:"credential,omitempty"`
+
+	CloneOptions *CloneOptions `json:"cloneOptions,omitempty"`
 }
 
 func DefaultRepositoryInfo() *RepositoryInfo {
@@ -31,9 +45,22 @@ func DefaultRepositoryInfo() *RepositoryInfo {
 }
 
 func (ri *RepositoryInfo) Clone() *RepositoryInfo {
-	return &RepositoryInfo{
-		Name:   ri.Name,
-		Url:    ri.Url,
-		Branch: ri.Branch,
+	c := &RepositoryInfo{
+		Name:           ri.Name,
+		Source:         ri.Source,
+		Url:            ri.Url,
+		Branch:         ri.Branch,
+		SubmoduleDepth: ri.SubmoduleDepth,
+		Credential:     ri.Credential,
 	}
+
+	if ri.Excludes != nil {
+		c.Excludes = append([]string{}, ri.Excludes...)
+	}
+
+	if ri.CloneOptions != nil {
+		c.CloneOptions = ri.CloneOptions.Clone()
+	}
+
+	return c
 }
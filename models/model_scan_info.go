@@ -26,6 +26,21 @@ type ScanInfo struct {
 
 	// the current execution status of this scan
 	Status string `json:"status"`
+
+	// type of scan performed, either 'tree', 'history' or 'incremental'
+	Mode string `json:"mode,omitempty"`
+
+	// SHA-1 hash of the HEAD commit that was scanned
+	Commit string `json:"commit,omitempty"`
+
+	// full name of the ref that was scanned, such as refs/heads/main or refs/tags/v1.0.0
+	Ref string `json:"ref,omitempty"`
+
+	// why the scan failed, such as a clone limit being exceeded
+	Reason string `json:"reason,omitempty"`
+
+	// hash of the rules and excludes that were applied by the scan
+	ConfigHash string `json:"configHash,omitempty"`
 }
 
 func DefaultScanInfo() *ScanInfo {
@@ -35,6 +50,7 @@ func DefaultScanInfo() *ScanInfo {
 		ScanningAt: "",
 		FinishedAt: "",
 		Status:     "QUEUED",
+		Mode:       "tree",
 	}
 }
 
@@ -45,5 +61,10 @@ func (si *ScanInfo) Clone() *ScanInfo {
 		ScanningAt: si.ScanningAt,
 		FinishedAt: si.FinishedAt,
 		Status:     si.Status,
+		Mode:       si.Mode,
+		Commit:     si.Commit,
+		Ref:        si.Ref,
+		Reason:     si.Reason,
+		ConfigHash: si.ConfigHash,
 	}
 }
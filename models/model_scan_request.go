@@ -0,0 +1,59 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package models
+
+type ScanRequest struct {
+
+	// type of scan to perform, either 'tree' for the checked-out tree, 'history' for every commit or 'incremental' for the changes since the last successful scan
+	Mode string `json:"mode,omitempty"`
+
+	// range of commits to scan in history mode
+	History *HistoryRange `json:"history,omitempty"`
+
+	// if present, the branch, tag or full ref name to scan instead of the branch of the repository, such as refs/pull/1/head
+	Ref string `json:"ref,omitempty"`
+
+	// if present, the SHA-1 hash of the commit to scan, which must be reachable from the ref
+	Commit string `json:"commit,omitempty"`
+}
+
+type HistoryRange struct {
+
+	// if present, commits reachable from this revision are not scanned
+	From string `json:"from,omitempty"`
+
+	// revision to start scanning from, defaults to the head of the branch
+	To string `json:"to,omitempty"`
+
+	// if present, the maximum number of commits to scan
+	MaxCommits int32 `json:"maxCommits,omitempty"`
+}
+
+func DefaultScanRequest() *ScanRequest {
+	return &ScanRequest{
+		Mode: "tree",
+	}
+}
+
+func (sr *ScanRequest) Clone() *ScanRequest {
+	c := &ScanRequest{
+		Mode:   sr.Mode,
+		Ref:    sr.Ref,
+		Commit: sr.Commit,
+	}
+
+	if sr.History != nil {
+		h := *sr.History
+		c.History = &h
+	}
+
+	return c
+}
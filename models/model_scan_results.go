@@ -18,4 +18,16 @@ type ScanResults struct {
 	Info *ScanInfo `json:"info"`
 
 	Findings []FindingsInfo `json:"findings"`
+
+	// number of findings suppressed by inline comments in the source code
+	SuppressedCount int32 `json:"suppressedCount"`
+
+	// findings suppressed by inline comments in the source code
+	Suppressed []FindingsInfo `json:"suppressed"`
+
+	// number of files that were not scanned
+	SkippedCount int32 `json:"skippedCount"`
+
+	// files that were not scanned, along with the reason
+	Skipped []SkippedFile `json:"skipped"`
 }
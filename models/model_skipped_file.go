@@ -0,0 +1,20 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package models
+
+type SkippedFile struct {
+
+	// the filename of the file that was not scanned
+	Path string `json:"path"`
+
+	// why the file was not scanned, either 'binary', 'too large', 'too deep' or 'unreadable'
+	Reason string `json:"reason"`
+}
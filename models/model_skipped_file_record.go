@@ -0,0 +1,7 @@
+package models
+
+type SkippedFileRecord struct {
+	Id     int          `json:"id"`
+	ScanId string       `json:"scanid"`
+	File   *SkippedFile `json:"file"`
+}
@@ -0,0 +1,23 @@
+/*
+ * Repository Secrets Scanner
+ *
+ * This is a simple backend API to allow a user to configure repositories for scanning, trigger a scan of those repositories, and retrieve the results.
+ *
+ * API version: 0.0.1
+ * Contact: sean.critica@gmail.com
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package models
+
+type SubmoduleInfo struct {
+
+	// path of the submodule from the root of the repository
+	Path string `json:"path"`
+
+	// URL the submodule was cloned from
+	Url string `json:"url"`
+
+	// SHA-1 hash of the commit of the submodule that was scanned
+	Sha string `json:"sha"`
+}